@@ -0,0 +1,521 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/davecgh/dcrstakesim/stakesim"
+)
+
+// config houses the configuration of dcrstakesim as parsed from the command
+// line flags and any scenario they load after it has been validated.
+type config struct {
+	cpuProfilePath string
+	csvPath        string
+	numBlocksList  string
+	numWorkers     int
+	poolSizeBand   float64
+	outputPath     string
+	noBrowser      bool
+	exportCSVPath  string
+	exportJSONPath string
+	summaryPath    string
+
+	// These fields are the ticket price functions, demand distribution
+	// functions along with their parameters, seeds, and numbers of blocks
+	// every combination of which is simulated.
+	pfs       []*stakesim.PriceFunc
+	dfs       []*stakesim.DemandFunc
+	dfParams  []stakesim.ModelParams
+	seeds     []int64
+	numBlocks []uint64
+
+	// sim is the configuration shared by all of the simulation runs.
+	sim *simConfig
+}
+
+// isFlagSet returns whether or not the flag with the provided name was set
+// either on the command line or by a scenario.
+func isFlagSet(name string) bool {
+	var isSet bool
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			isSet = true
+		}
+	})
+	return isSet
+}
+
+// parseModelParams parses the passed comma-separated list of name=value pairs
+// which override the tunable parameters of the provided model and returns the
+// resulting parameter values.  An error is returned when the model does not
+// accept one of the parameters or the values are outside of the range it
+// supports.
+func parseModelParams(m *stakesim.Model, list string) (stakesim.ModelParams, error) {
+	overrides, err := stakesim.ParseModelParams(list)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.CheckOverrides(overrides); err != nil {
+		return nil, err
+	}
+	return m.ParamValues(overrides)
+}
+
+// parseFeatureParams is the equivalent of parseModelParams for the tunable
+// parameters of a simulator feature, such as the ticket mempool, which are
+// validated by the provided function.
+func parseFeatureParams(name string, defs []stakesim.ModelParam, list string, validate func(stakesim.ModelParams) error) (stakesim.ModelParams, error) {
+	overrides, err := stakesim.ParseModelParams(list)
+	if err != nil {
+		return nil, err
+	}
+	return stakesim.ModelParamValues(name, defs, overrides, validate)
+}
+
+// loadConfig defines and parses the command line flags, loads the scenario
+// they specify, if any, and validates the resulting configuration.
+//
+// The available models are shown instead when any of the flags that select
+// them or their parameters is list, in which case a nil config is returned
+// without an error.
+func loadConfig() (*config, error) {
+	var cpuProfilePath = flag.String("cpuprofile", "",
+		"Write CPU profile to the specified file")
+	var scenarioPath = flag.String("scenario", "",
+		"Load the simulation setup from the specified JSON scenario "+
+			"file -- flags provided on the command line override "+
+			"the values in the scenario")
+	var csvPath = flag.String("inputcsv", "",
+		"Path to simulation CSV input data -- This overrides numblocks")
+	var numBlocksList = flag.String("numblocks", "100000",
+		"Number of blocks to simulate -- multiple comma-separated "+
+			"values may be specified")
+	var netName = flag.String("net", "mainnet",
+		"Set the network whose chain parameters are simulated -- "+
+			"available options: ["+strings.Join(netNames(), ", ")+"]")
+	var netParamsList = flag.String("netparams", "",
+		"Comma-separated list of name=value pairs to override chain "+
+			"parameters of the network -- available names: ["+
+			strings.Join(netParamNames, ", ")+"]")
+	var pfNames = flag.String("pf", "current",
+		"Set the ticket price calculation function -- available options: ["+
+			strings.Join(stakesim.PriceFuncKeys(), ", ")+"] -- multiple "+
+			"comma-separated functions may be specified to compare them")
+	var ddfNames = flag.String("ddf", "a",
+		"Set the demand distribution function -- available options: ["+
+			strings.Join(stakesim.DemandFuncKeys(), ", ")+"] -- use list to "+
+			"show their descriptions and parameters -- multiple "+
+			"comma-separated functions may be specified")
+	var ddfParams = flag.String("ddfparams", "",
+		"Comma-separated list of name=value pairs to override the "+
+			"default parameters of the demand distribution functions")
+	var missModelName = flag.String("missmodel", "none",
+		"Set the model for missed votes -- available options: ["+
+			strings.Join(stakesim.MissModelKeys(), ", ")+"] -- use list to "+
+			"show their descriptions and parameters")
+	var missParamsList = flag.String("missparams", "",
+		"Comma-separated list of name=value pairs to override the "+
+			"default parameters of the missed vote model")
+	var revokeModelName = flag.String("revokemodel", "immediate",
+		"Set the model for revoking missed and expired tickets -- "+
+			"available options: ["+
+			strings.Join(stakesim.RevocationModelKeys(), ", ")+"] -- use "+
+			"list to show their descriptions and parameters")
+	var revokeParamsList = flag.String("revokeparams", "",
+		"Comma-separated list of name=value pairs to override the "+
+			"default parameters of the revocation model")
+	var autoRevocationsHeight = flag.Int("autorevocations", -1,
+		"Height at which missed and expired tickets start being "+
+			"automatically revoked in the same block per DCP0009 "+
+			"-- negative values disable automatic revocations")
+	var noiseModelName = flag.String("noise", "none",
+		"Set the model for random noise applied to the demand -- "+
+			"available options: ["+
+			strings.Join(stakesim.NoiseModelKeys(), ", ")+"] -- use list to "+
+			"show their descriptions and parameters")
+	var noiseParamsList = flag.String("noiseparams", "",
+		"Comma-separated list of name=value pairs to override the "+
+			"default parameters of the noise model")
+	var profileName = flag.String("purchaseprofile", "even",
+		"Set how the purchases demanded for each window are "+
+			"distributed across its blocks -- available options: ["+
+			strings.Join(stakesim.PurchaseProfileKeys(), ", ")+"] -- use "+
+			"list to show their descriptions and parameters")
+	var profileParamsList = flag.String("profileparams", "",
+		"Comma-separated list of name=value pairs to override the "+
+			"default parameters of the purchase profile")
+	var useMempool = flag.Bool("mempool", false,
+		"Simulate a ticket mempool where purchases compete for the "+
+			"new tickets allowed in each block by fee and expire "+
+			"when the ticket price changes")
+	var mempoolParamsList = flag.String("mempoolparams", "",
+		"Comma-separated list of name=value pairs to override the "+
+			"default parameters of the ticket mempool -- use list "+
+			"to show them")
+	var numAgents = flag.Int("agents", 0,
+		"Number of stakeholder agents which decide the ticket "+
+			"purchases instead of the demand distribution function "+
+			"-- 0 disables the agents")
+	var agentParamsList = flag.String("agentparams", "",
+		"Comma-separated list of name=value pairs to override the "+
+			"default parameters of the stakeholder agents -- use "+
+			"list to show them")
+	var splitTickets = flag.Bool("splittickets", false,
+		"Allow stakeholder agents that cannot afford a full ticket to "+
+			"pool their balances into split tickets")
+	var splitParamsList = flag.String("splitparams", "",
+		"Comma-separated list of name=value pairs to override the "+
+			"default parameters of the split tickets -- use list to "+
+			"show them")
+	var vspList = flag.String("vsps", "",
+		"Comma-separated list of voting service providers of the form "+
+			"share:fee:uptime which vote the given fraction of the "+
+			"purchased tickets for a fee of the vote reward -- "+
+			"values may be fractions or percentages such as 2% and "+
+			"the remaining tickets are held by solo stakers")
+	var eventList = flag.String("events", stakesim.DefaultEvents,
+		"Comma-separated list of events of the form kind:start-end:value "+
+			"which change ticket purchasing during the simulation -- "+
+			"kinds: demand (multiply demand), stakecap (fraction of "+
+			"supply that may be staked), freeze (stop purchases until "+
+			"staked coins drop by a fraction) -- heights may be a "+
+			"percentage of numblocks such as 60%")
+	var stakeCap = flag.Float64("stakecap", 0.4,
+		"Maximum fraction of the total supply that will be staked "+
+			"outside of any events that change it")
+	var seedList = flag.String("seed", "0",
+		"Seed for the lottery and the random source of the noise, "+
+			"miss, and revocation models which makes runs exactly "+
+			"reproducible -- multiple comma-separated values may "+
+			"be specified")
+	var monteCarloRuns = flag.Int("runs", 1,
+		"Number of Monte Carlo runs of every configuration which use "+
+			"consecutive seeds starting at the seed -- the results "+
+			"show percentile bands across the runs and the noise "+
+			"model defaults to gaussian so the demand is perturbed")
+	var numWorkers = flag.Int("workers", runtime.NumCPU(),
+		"Number of simulations to run concurrently")
+	var outputPath = flag.String("output", "",
+		"Write the results to the specified file instead of a "+
+			"generated file in the temp directory")
+	var noBrowser = flag.Bool("nobrowser", false,
+		"Do not open the results in a browser")
+	var verify = flag.Bool("verify", false,
+		"Verify the ticket price and pool size of every block against "+
+			"the values committed to by its header in the CSV input "+
+			"data and report any divergence")
+	var exportCSVPath = flag.String("exportcsv", "",
+		"Export the details of every simulated block to the specified "+
+			"CSV file")
+	var exportJSONPath = flag.String("exportjson", "",
+		"Export the details of every simulated block to the specified "+
+			"file as newline-delimited JSON")
+	var summaryPath = flag.String("summary", "",
+		"Write a JSON summary of the simulation results to the "+
+			"specified file")
+	var poolSizeBand = flag.Float64("poolsizeband", 10,
+		"Percentage above and below the target pool size considered "+
+			"acceptable when summarizing the results")
+	var verbose = flag.Bool("verbose", false, "Print additional details about simulator state")
+	flag.Parse()
+
+	// Load the simulation setup from a scenario file when requested.
+	if *scenarioPath != "" {
+		sc, err := loadScenario(*scenarioPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := sc.apply(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Show the available demand distribution functions and models when
+	// requested.
+	switch {
+	case *ddfNames == "list":
+		stakesim.PrintDemandFuncs(os.Stdout)
+		return nil, nil
+	case *missModelName == "list":
+		stakesim.PrintMissModels(os.Stdout)
+		return nil, nil
+	case *profileName == "list":
+		stakesim.PrintPurchaseProfiles(os.Stdout)
+		return nil, nil
+	case *mempoolParamsList == "list":
+		fmt.Println("Ticket mempool parameters:")
+		stakesim.PrintModelParams(os.Stdout, stakesim.MempoolParams)
+		return nil, nil
+	case *agentParamsList == "list":
+		stakesim.PrintAgentParams(os.Stdout)
+		return nil, nil
+	case *splitParamsList == "list":
+		fmt.Println("Split ticket parameters:")
+		stakesim.PrintModelParams(os.Stdout, stakesim.SplitParams)
+		return nil, nil
+	case *revokeModelName == "list":
+		stakesim.PrintRevocationModels(os.Stdout)
+		return nil, nil
+	case *noiseModelName == "list":
+		stakesim.PrintNoiseModels(os.Stdout)
+		return nil, nil
+	}
+
+	cfg := &config{
+		cpuProfilePath: *cpuProfilePath,
+		csvPath:        *csvPath,
+		numBlocksList:  *numBlocksList,
+		numWorkers:     *numWorkers,
+		poolSizeBand:   *poolSizeBand,
+		outputPath:     *outputPath,
+		noBrowser:      *noBrowser,
+		exportCSVPath:  *exportCSVPath,
+		exportJSONPath: *exportJSONPath,
+		summaryPath:    *summaryPath,
+	}
+
+	// Look up the requested ticket price functions.  New functions are made
+	// available by registering them with RegisterPriceFunc from an init
+	// function in the file that defines them.
+	for _, pfName := range stakesim.SplitList(*pfNames) {
+		pf := stakesim.LookupPriceFunc(pfName)
+		if pf == nil {
+			return nil, fmt.Errorf("%q is not a valid ticket price "+
+				"func name", pfName)
+		}
+		cfg.pfs = append(cfg.pfs, pf)
+	}
+
+	// Look up the requested demand distribution functions.  New functions
+	// are made available by registering them with RegisterDemandFunc from
+	// an init function in the file that defines them.
+	for _, ddfName := range stakesim.SplitList(*ddfNames) {
+		df := stakesim.LookupDemandFunc(ddfName)
+		if df == nil {
+			return nil, fmt.Errorf("%q is not a valid demand "+
+				"distribution func name", ddfName)
+		}
+		cfg.dfs = append(cfg.dfs, df)
+	}
+
+	// Parse any tunable parameters for the demand distribution functions
+	// and ensure each of them is accepted by at least one of them.
+	dfOverrides, err := stakesim.ParseModelParams(*ddfParams)
+	if err != nil {
+		return nil, err
+	}
+	for name := range dfOverrides {
+		var accepted bool
+		for _, df := range cfg.dfs {
+			accepted = accepted || df.Accepts(name)
+		}
+		if !accepted {
+			return nil, fmt.Errorf("no selected demand distribution "+
+				"func accepts a parameter named %q", name)
+		}
+	}
+	for _, df := range cfg.dfs {
+		dfParams, err := df.ParamValues(dfOverrides)
+		if err != nil {
+			return nil, err
+		}
+		cfg.dfParams = append(cfg.dfParams, dfParams)
+	}
+
+	// Look up the requested miss model and parse any overrides of its
+	// tunable parameters.
+	mm := stakesim.LookupMissModel(*missModelName)
+	if mm == nil {
+		return nil, fmt.Errorf("%q is not a valid miss model name",
+			*missModelName)
+	}
+	missParams, err := parseModelParams(&mm.Model, *missParamsList)
+	if err != nil {
+		return nil, err
+	}
+
+	// Look up the requested revocation model and parse any overrides of its
+	// tunable parameters.
+	rm := stakesim.LookupRevocationModel(*revokeModelName)
+	if rm == nil {
+		return nil, fmt.Errorf("%q is not a valid revocation model name",
+			*revokeModelName)
+	}
+	revokeParams, err := parseModelParams(&rm.Model, *revokeParamsList)
+	if err != nil {
+		return nil, err
+	}
+
+	// Perturb the demand of Monte Carlo runs with noise unless a noise
+	// model was explicitly requested.
+	if *monteCarloRuns < 1 {
+		return nil, fmt.Errorf("number of runs %d must be at least 1",
+			*monteCarloRuns)
+	}
+	if *monteCarloRuns > 1 && !isFlagSet("noise") {
+		*noiseModelName = "gaussian"
+		fmt.Println("Using the gaussian noise model to perturb the " +
+			"demand of the Monte Carlo runs")
+	}
+
+	// Look up the requested noise model and parse any overrides of its
+	// tunable parameters.
+	nm := stakesim.LookupNoiseModel(*noiseModelName)
+	if nm == nil {
+		return nil, fmt.Errorf("%q is not a valid noise model name",
+			*noiseModelName)
+	}
+	noiseParams, err := parseModelParams(&nm.Model, *noiseParamsList)
+	if err != nil {
+		return nil, err
+	}
+
+	// Look up the requested purchase profile and parse any overrides of
+	// its tunable parameters.
+	pp := stakesim.LookupPurchaseProfile(*profileName)
+	if pp == nil {
+		return nil, fmt.Errorf("%q is not a valid purchase profile name",
+			*profileName)
+	}
+	profileParams, err := parseModelParams(&pp.Model, *profileParamsList)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse any overrides of the parameters of the ticket mempool.
+	mempoolParams, err := parseFeatureParams("ticket mempool",
+		stakesim.MempoolParams, *mempoolParamsList,
+		stakesim.ValidateMempoolParams)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse any overrides of the parameters of the stakeholder agents.
+	if *numAgents < 0 {
+		return nil, fmt.Errorf("number of agents %d must not be "+
+			"negative", *numAgents)
+	}
+	agentParams, err := parseFeatureParams("stakeholder agent",
+		stakesim.AgentParams, *agentParamsList,
+		stakesim.ValidateAgentParams)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse any overrides of the parameters of the split tickets, which are
+	// only purchased by stakeholder agents.
+	if *splitTickets && *numAgents == 0 {
+		return nil, fmt.Errorf("split tickets require stakeholder agents")
+	}
+	splitParams, err := parseFeatureParams("split ticket",
+		stakesim.SplitParams, *splitParamsList,
+		stakesim.ValidateSplitParams)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verification compares the simulated blocks against the headers of
+	// the CSV input data, so it is not possible without them.
+	if *verify && *csvPath == "" {
+		return nil, fmt.Errorf("verification requires CSV input data")
+	}
+
+	// Parse the voting service providers tickets are delegated to.
+	vsps, err := stakesim.ParseVotingServices(*vspList)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the events that change ticket purchasing.
+	events, err := stakesim.ParseEvents(*eventList)
+	if err != nil {
+		return nil, err
+	}
+	if *stakeCap <= 0 || *stakeCap > 1 {
+		return nil, fmt.Errorf("stake cap %v is not in the range (0, 1]",
+			*stakeCap)
+	}
+
+	// Create the chain parameters for the requested network with any
+	// overrides applied.
+	netOverrides, err := parseNetParamOverrides(*netParamsList)
+	if err != nil {
+		return nil, err
+	}
+	params, err := newNetParams(*netName, netOverrides)
+	if err != nil {
+		return nil, err
+	}
+	cfg.sim = &simConfig{
+		net:                   *netName,
+		netOverrides:          netOverrides,
+		params:                params,
+		stakeCap:              *stakeCap,
+		events:                events,
+		missModel:             mm,
+		missParams:            missParams,
+		revokeModel:           rm,
+		revokeParams:          revokeParams,
+		noiseModel:            nm,
+		noiseParams:           noiseParams,
+		purchaseProfile:       pp,
+		profileParams:         profileParams,
+		mempool:               *useMempool,
+		mempoolParams:         mempoolParams,
+		numAgents:             *numAgents,
+		agentParams:           agentParams,
+		splitTickets:          *splitTickets,
+		splitParams:           splitParams,
+		vsps:                  vsps,
+		verify:                *verify,
+		autoRevocationsHeight: int32(*autoRevocationsHeight),
+		monteCarloRuns:        *monteCarloRuns,
+		verbose:               *verbose,
+	}
+
+	// Parse the seeds and number of blocks to simulate.
+	for _, seedStr := range stakesim.SplitList(*seedList) {
+		seed, err := strconv.ParseInt(seedStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seed %q: %v", seedStr,
+				err)
+		}
+		cfg.seeds = append(cfg.seeds, seed)
+	}
+	if *monteCarloRuns > 1 {
+		switch {
+		case *csvPath != "":
+			return nil, fmt.Errorf("multiple runs may not be used " +
+				"with CSV input data")
+		case len(cfg.seeds) != 1:
+			return nil, fmt.Errorf("multiple runs require a single " +
+				"starting seed")
+		}
+		for i := 1; i < *monteCarloRuns; i++ {
+			cfg.seeds = append(cfg.seeds, cfg.seeds[0]+int64(i))
+		}
+	}
+	for _, numBlocksStr := range stakesim.SplitList(*numBlocksList) {
+		numBlocks, err := strconv.ParseUint(numBlocksStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number of blocks %q: %v",
+				numBlocksStr, err)
+		}
+		if numBlocks == 0 {
+			return nil, fmt.Errorf("number of blocks must be at " +
+				"least 1")
+		}
+		cfg.numBlocks = append(cfg.numBlocks, numBlocks)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,427 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/davecgh/dcrstakesim/stakesim"
+	"github.com/decred/dcrutil"
+)
+
+// percentiles houses the 5th, 50th, and 95th percentiles of a series of values.
+type percentiles struct {
+	P5  float64 `json:"p5"`
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+}
+
+// percentile returns the requested percentile, expressed as a fraction, of the
+// passed sorted values using linear interpolation between the closest ranks.
+// Zero is returned when there are no values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p * float64(len(sorted)-1)
+	lower := int(rank)
+	if lower+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	frac := rank - float64(lower)
+	return sorted[lower] + (sorted[lower+1]-sorted[lower])*frac
+}
+
+// calcPercentiles returns the 5th, 50th, and 95th percentiles of the passed
+// values.  The passed slice will be sorted in place.
+func calcPercentiles(values []float64) percentiles {
+	sort.Float64s(values)
+	return percentiles{
+		P5:  percentile(values, 0.05),
+		P50: percentile(values, 0.5),
+		P95: percentile(values, 0.95),
+	}
+}
+
+// monteCarloDistribution houses the distributions of the summary metrics of
+// all of the Monte Carlo runs of a ticket price function.  The metrics have the
+// same meaning and units as their counterparts in Summary.
+type monteCarloDistribution struct {
+	PriceFunc  string `json:"priceFunc"`
+	DemandFunc string `json:"demandFunc"`
+	FirstSeed  int64  `json:"firstSeed"`
+	NumRuns    int    `json:"numRuns"`
+	Height     int32  `json:"height"`
+
+	MinTicketPrice     percentiles `json:"minTicketPrice"`
+	MaxTicketPrice     percentiles `json:"maxTicketPrice"`
+	MeanTicketPrice    percentiles `json:"meanTicketPrice"`
+	MinPoolSize        percentiles `json:"minPoolSize"`
+	MaxPoolSize        percentiles `json:"maxPoolSize"`
+	MeanPoolSize       percentiles `json:"meanPoolSize"`
+	PercentOutsideBand percentiles `json:"percentOutsideBand"`
+	AvgVoteWaitDays    percentiles `json:"avgVoteWaitDays"`
+	ExpiredPercent     percentiles `json:"expiredPercent"`
+	MissRate           percentiles `json:"missRate"`
+	AvgStakedFraction  percentiles `json:"avgStakedFraction"`
+
+	// PricedOutStakeholders is only set when purchases are decided by
+	// stakeholder agents and SoloNetYield and PooledNetYield are only set
+	// when VSPs are simulated.
+	PricedOutStakeholders *percentiles `json:"pricedOutStakeholders,omitempty"`
+	SoloNetYield          *percentiles `json:"soloNetYield,omitempty"`
+	PooledNetYield        *percentiles `json:"pooledNetYield,omitempty"`
+}
+
+// summarizeMonteCarlo returns the distributions of the summary metrics of the
+// passed completed Monte Carlo runs, which must all use the same ticket price
+// function.
+func summarizeMonteCarlo(runs []*simRun) *monteCarloDistribution {
+	metric := func(value func(summary *stakesim.Summary) float64) percentiles {
+		values := make([]float64, 0, len(runs))
+		for _, run := range runs {
+			values = append(values, value(run.summary))
+		}
+		return calcPercentiles(values)
+	}
+
+	first := runs[0].summary
+	dist := &monteCarloDistribution{
+		PriceFunc:  first.PriceFunc,
+		DemandFunc: first.DemandFunc,
+		FirstSeed:  first.Seed,
+		NumRuns:    len(runs),
+		Height:     first.Height,
+		MinTicketPrice: metric(func(s *stakesim.Summary) float64 {
+			return float64(s.MinTicketPrice)
+		}),
+		MaxTicketPrice: metric(func(s *stakesim.Summary) float64 {
+			return float64(s.MaxTicketPrice)
+		}),
+		MeanTicketPrice: metric(func(s *stakesim.Summary) float64 {
+			return s.TicketPrice.Mean
+		}),
+		MinPoolSize: metric(func(s *stakesim.Summary) float64 {
+			return float64(s.MinPoolSize)
+		}),
+		MaxPoolSize: metric(func(s *stakesim.Summary) float64 {
+			return float64(s.MaxPoolSize)
+		}),
+		MeanPoolSize: metric(func(s *stakesim.Summary) float64 {
+			return s.PoolSize.Mean
+		}),
+		PercentOutsideBand: metric(func(s *stakesim.Summary) float64 {
+			return s.PercentOutsideBand
+		}),
+		AvgVoteWaitDays: metric(func(s *stakesim.Summary) float64 {
+			return s.AvgVoteWaitDays
+		}),
+		ExpiredPercent: metric(func(s *stakesim.Summary) float64 {
+			return s.ExpiredPercent
+		}),
+		MissRate: metric(func(s *stakesim.Summary) float64 {
+			return s.MissRate
+		}),
+		AvgStakedFraction: metric(func(s *stakesim.Summary) float64 {
+			return s.AvgStakedFraction
+		}),
+	}
+	if runs[0].cfg.numAgents > 0 {
+		pricedOut := metric(func(s *stakesim.Summary) float64 {
+			return float64(s.PricedOutStakeholders)
+		})
+		dist.PricedOutStakeholders = &pricedOut
+	}
+	if first.Solo != nil && first.Pooled != nil {
+		soloNetYield := metric(func(s *stakesim.Summary) float64 {
+			return s.Solo.NetYield
+		})
+		pooledNetYield := metric(func(s *stakesim.Summary) float64 {
+			return s.Pooled.NetYield
+		})
+		dist.SoloNetYield = &soloNetYield
+		dist.PooledNetYield = &pooledNetYield
+	}
+	return dist
+}
+
+// writeCSVBand writes the 5th, 50th, and 95th percentiles of the values of the
+// passed nodes, in the low;mid;high form expected by the custom bars of the
+// results charts, to the provided buffer preceded by a comma.  Nil nodes are
+// skipped and the value is left empty when there are no nodes.
+func writeCSVBand(buf *bytes.Buffer, nodes []*stakesim.BlockNode, value func(node *stakesim.BlockNode) float64) {
+	buf.WriteRune(',')
+	values := make([]float64, 0, len(nodes))
+	for _, node := range nodes {
+		if node != nil {
+			values = append(values, value(node))
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+	p := calcPercentiles(values)
+	buf.WriteString(strconv.FormatFloat(p.P5, 'f', 8, 64))
+	buf.WriteRune(';')
+	buf.WriteString(strconv.FormatFloat(p.P50, 'f', 8, 64))
+	buf.WriteRune(';')
+	buf.WriteString(strconv.FormatFloat(p.P95, 'f', 8, 64))
+}
+
+// distributionRow houses the name of a summary metric along with the formatted
+// distribution of it for each ticket price function in the Monte Carlo
+// results.
+type distributionRow struct {
+	Name   string
+	Values []string
+}
+
+// generateMonteCarloResults creates an HTML results file for the passed group
+// of completed Monte Carlo runs.  The charts show the median of every ticket
+// price function across all of its runs at each height as a line surrounded by
+// a shaded band spanning the 5th to 95th percentiles and the summary table
+// shows the same percentiles of the summary metrics.
+//
+// The distributions of the group must already have been calculated.
+func generateMonteCarloResults(group *runGroup) error {
+	pfRuns, resultsPath := group.runsByPriceFunc(), group.resultsPath
+
+	// Parse the results template.
+	resultsTpl, err := template.New("results").Parse(resultsTmplText)
+	if err != nil {
+		return fmt.Errorf("unable to parse results template: %v", err)
+	}
+	resultsFile, err := os.Create(resultsPath)
+	if err != nil {
+		return fmt.Errorf("unable to create results: %v", err)
+	}
+	defer resultsFile.Close()
+
+	// Shorter version of some params for convenience.
+	firstRun := pfRuns[0][0]
+	windowSize := int32(firstRun.sim.Params().StakeDiffWindowSize)
+
+	// Generate the data needed for the HTML template and execute it in
+	// order to generate the final HTML results file.  Each line of the CSV
+	// data contains the percentiles for all of the ticket price functions
+	// across their runs at a given height.
+	var poolSizeCSV, ticketPriceCSV, supplyCSV, mempoolCSV bytes.Buffer
+	hasMempool := firstRun.cfg.mempool
+	nodes := make([][]*stakesim.BlockNode, len(pfRuns))
+	for i, runs := range pfRuns {
+		nodes[i] = make([]*stakesim.BlockNode, len(runs))
+		for j, run := range runs {
+			nodes[i][j] = run.sim.Root()
+		}
+	}
+	poolSize := func(node *stakesim.BlockNode) float64 {
+		return float64(node.PoolSize())
+	}
+	ticketPrice := func(node *stakesim.BlockNode) float64 {
+		return dcrutil.Amount(node.TicketPrice()).ToCoin()
+	}
+	totalSupply := func(node *stakesim.BlockNode) float64 {
+		return node.TotalSupply().ToCoin() / 1e6
+	}
+	stakedSupply := func(node *stakesim.BlockNode) float64 {
+		return node.StakedCoins().ToCoin() / 1e6
+	}
+	mempoolDepth := func(node *stakesim.BlockNode) float64 {
+		return float64(node.MempoolDepth())
+	}
+	for {
+		// Find the height of the next line from the first run which
+		// still has blocks.
+		var first *stakesim.BlockNode
+		for _, runNodes := range nodes {
+			for _, node := range runNodes {
+				if first == nil && node != nil {
+					first = node
+				}
+			}
+		}
+		if first == nil {
+			break
+		}
+
+		heightStr := strconv.Itoa(int(first.Height()))
+		poolSizeCSV.WriteString(heightStr)
+		if hasMempool {
+			mempoolCSV.WriteString(heightStr)
+		}
+		supplyCSV.WriteString(heightStr)
+		writeCSVBand(&supplyCSV, nodes[0], totalSupply)
+		isRetarget := first.Height()%windowSize == 0
+		if isRetarget {
+			ticketPriceCSV.WriteString(heightStr)
+		}
+		for _, runNodes := range nodes {
+			writeCSVBand(&poolSizeCSV, runNodes, poolSize)
+			if isRetarget {
+				writeCSVBand(&ticketPriceCSV, runNodes, ticketPrice)
+			}
+			writeCSVBand(&supplyCSV, runNodes, stakedSupply)
+			if hasMempool {
+				writeCSVBand(&mempoolCSV, runNodes, mempoolDepth)
+			}
+			for j, node := range runNodes {
+				if node != nil {
+					runNodes[j] = node.Next()
+				}
+			}
+		}
+		poolSizeCSV.WriteRune('\n')
+		if hasMempool {
+			mempoolCSV.WriteRune('\n')
+		}
+		supplyCSV.WriteRune('\n')
+		if isRetarget {
+			ticketPriceCSV.WriteRune('\n')
+		}
+	}
+
+	// Generate the per price function labels and summary metric
+	// distributions.
+	formatDist := func(p percentiles, format func(float64) string) string {
+		return fmt.Sprintf("%s (%s – %s)", format(p.P50),
+			format(p.P5), format(p.P95))
+	}
+	formatAmount := func(v float64) string {
+		return dcrutil.Amount(v).String()
+	}
+	formatFloat := func(prec int, suffix string) func(float64) string {
+		return func(v float64) string {
+			return strconv.FormatFloat(v, 'f', prec, 64) + suffix
+		}
+	}
+	poolSizeBand := firstRun.summary.PoolSizeBand
+	rows := []distributionRow{
+		{Name: "Runs"},
+		{Name: "Min Ticket Price"},
+		{Name: "Max Ticket Price"},
+		{Name: "Mean Ticket Price"},
+		{Name: "Mean Pool Size"},
+		{Name: fmt.Sprintf("Blocks With Pool Size Outside ±%v%% "+
+			"of Target", poolSizeBand)},
+		{Name: "Average Vote Wait"},
+		{Name: "Expired Tickets"},
+		{Name: "Missed Votes"},
+		{Name: "Average Staked Supply"},
+	}
+	hasAgents := firstRun.cfg.numAgents > 0
+	if hasAgents {
+		rows = append(rows, distributionRow{Name: "Priced Out Stakeholders"})
+	}
+	hasVotingServices := len(firstRun.cfg.vsps) > 0
+	if hasVotingServices {
+		rows = append(rows, distributionRow{Name: "Net Yield of Solo Tickets"},
+			distributionRow{Name: "Net Yield of Pooled Tickets"})
+	}
+	priceFuncNames := make([]string, 0, len(pfRuns))
+	seriesLabels := make([]string, 0, len(pfRuns))
+	stakedLabels := make([]string, 0, len(pfRuns))
+	colors := make([]string, 0, len(pfRuns))
+	for i, runs := range pfRuns {
+		dist := group.distributions[i]
+		values := []string{
+			strconv.Itoa(dist.NumRuns),
+			formatDist(dist.MinTicketPrice, formatAmount),
+			formatDist(dist.MaxTicketPrice, formatAmount),
+			formatDist(dist.MeanTicketPrice, formatAmount),
+			formatDist(dist.MeanPoolSize, formatFloat(0, "")),
+			formatDist(dist.PercentOutsideBand, formatFloat(2, "%")),
+			formatDist(dist.AvgVoteWaitDays, formatFloat(2, " days")),
+			formatDist(dist.ExpiredPercent, formatFloat(2, "%")),
+			formatDist(dist.MissRate, func(v float64) string {
+				return formatFloat(2, "%")(v * 100)
+			}),
+			formatDist(dist.AvgStakedFraction, func(v float64) string {
+				return formatFloat(2, "%")(v * 100)
+			}),
+		}
+		if hasAgents {
+			values = append(values, formatDist(
+				*dist.PricedOutStakeholders, formatFloat(0, "")))
+		}
+		if hasVotingServices {
+			formatPercent := func(v float64) string {
+				return formatFloat(2, "%")(v * 100)
+			}
+			values = append(values,
+				formatDist(*dist.SoloNetYield, formatPercent),
+				formatDist(*dist.PooledNetYield, formatPercent))
+		}
+		for j := range rows {
+			rows[j].Values = append(rows[j].Values, values[j])
+		}
+
+		// Use generic labels when there is only a single price function
+		// to match the chart titles.
+		pf := runs[0].pf
+		priceFuncNames = append(priceFuncNames, pf.Description())
+		seriesLabel, stakedLabel := "Pool Size", "Staked Supply"
+		if len(pfRuns) > 1 {
+			seriesLabel = pf.Name
+			stakedLabel = "Staked Supply (" + pf.Name + ")"
+		}
+		seriesLabels = append(seriesLabels, seriesLabel)
+		stakedLabels = append(stakedLabels, stakedLabel)
+		colors = append(colors, resultsColors[i%len(resultsColors)])
+	}
+	ticketPriceLabels, mempoolLabels := seriesLabels, seriesLabels
+	poolSizeColors, ticketPriceColors := colors, colors
+	supplyColors := append([]string{"#fd714a"}, colors...)
+	if len(pfRuns) == 1 {
+		ticketPriceLabels = []string{"Ticket Price"}
+		mempoolLabels = []string{"Mempool Depth"}
+		poolSizeColors = []string{"#0c1e3e"}
+		ticketPriceColors = []string{"#2972ff"}
+		supplyColors = []string{"#0c1e3e", "#2972ff"}
+	}
+
+	lastSeed := firstRun.seed + int64(len(pfRuns[0])) - 1
+	seeds := fmt.Sprintf("%d to %d (%d Monte Carlo runs)", firstRun.seed,
+		lastSeed, len(pfRuns[0]))
+	parameters := resultsParameters(firstRun, seeds)
+	events := resultsEvents(firstRun.sim)
+
+	scenarioJSON, err := json.MarshalIndent(groupScenario(group), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = resultsTpl.Execute(resultsFile, map[string]interface{}{
+		"PoolSizeCSV":       poolSizeCSV.String(),
+		"TicketPriceCSV":    ticketPriceCSV.String(),
+		"SupplyCSV":         supplyCSV.String(),
+		"MempoolCSV":        mempoolCSV.String(),
+		"PriceFuncNames":    priceFuncNames,
+		"Distributions":     rows,
+		"NumRuns":           len(pfRuns),
+		"PoolSizeLabels":    append([]string{"Block"}, seriesLabels...),
+		"TicketPriceLabels": append([]string{"Block"}, ticketPriceLabels...),
+		"SupplyLabels":      append([]string{"Block", "Total Supply"}, stakedLabels...),
+		"MempoolLabels":     append([]string{"Block"}, mempoolLabels...),
+		"PoolSizeColors":    poolSizeColors,
+		"TicketPriceColors": ticketPriceColors,
+		"SupplyColors":      supplyColors,
+		"FillGraph":         false,
+		"CustomBars":        true,
+		"Parameters":        parameters,
+		"Events":            events,
+		"Scenario":          string(scenarioJSON),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to execute template: %v", err)
+	}
+
+	return nil
+}
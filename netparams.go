@@ -0,0 +1,182 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/decred/dcrd/chaincfg"
+)
+
+// netParamsByName provides a lookup of the chain parameters that may be
+// simulated by the name used to select them with the -net flag.
+var netParamsByName = map[string]*chaincfg.Params{
+	"mainnet": &chaincfg.MainNetParams,
+	"testnet": &chaincfg.TestNet2Params,
+	"simnet":  &chaincfg.SimNetParams,
+}
+
+// netNames returns the names of all networks that may be simulated in sorted
+// order.
+func netNames() []string {
+	names := make([]string, 0, len(netParamsByName))
+	for name := range netParamsByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// netParamNames are the lowercase names of the chain parameters which may be
+// overridden.
+var netParamNames = []string{"ticketpoolsize", "ticketsperblock",
+	"stakediffwindowsize", "ticketmaturity", "ticketexpiry",
+	"maxfreshstakeperblock", "workrewardproportion",
+	"stakerewardproportion", "blocktaxproportion"}
+
+// isNetParamName returns whether or not the provided lowercase name refers to a
+// chain parameter which may be overridden.
+func isNetParamName(name string) bool {
+	for _, paramName := range netParamNames {
+		if name == paramName {
+			return true
+		}
+	}
+	return false
+}
+
+// setNetParam sets the chain parameter with the provided lowercase name to the
+// given value after ensuring it fits in the type of the parameter.  The stake
+// difficulty window size is limited to the range of an int32 since the
+// simulator works with heights of that type.
+func setNetParam(params *chaincfg.Params, name string, value uint64) error {
+	var max uint64
+	switch name {
+	case "maxfreshstakeperblock":
+		max = math.MaxUint8
+	case "ticketexpiry":
+		max = math.MaxUint32
+	case "stakediffwindowsize":
+		max = math.MaxInt32
+	default:
+		max = math.MaxUint16
+	}
+	if value > max {
+		return fmt.Errorf("value %d exceeds max %d", value, max)
+	}
+
+	switch name {
+	case "ticketpoolsize":
+		params.TicketPoolSize = uint16(value)
+	case "ticketsperblock":
+		params.TicketsPerBlock = uint16(value)
+	case "stakediffwindowsize":
+		params.StakeDiffWindowSize = int64(value)
+	case "ticketmaturity":
+		params.TicketMaturity = uint16(value)
+	case "ticketexpiry":
+		params.TicketExpiry = uint32(value)
+	case "maxfreshstakeperblock":
+		params.MaxFreshStakePerBlock = uint8(value)
+	case "workrewardproportion":
+		params.WorkRewardProportion = uint16(value)
+	case "stakerewardproportion":
+		params.StakeRewardProportion = uint16(value)
+	case "blocktaxproportion":
+		params.BlockTaxProportion = uint16(value)
+	default:
+		return fmt.Errorf("chain parameter %q may not be overridden",
+			name)
+	}
+	return nil
+}
+
+// netParamOverrides houses values which override chain parameters keyed by the
+// lowercase name of the parameter.
+type netParamOverrides map[string]uint64
+
+// String returns the overrides as a comma-separated list of name=value pairs
+// sorted by name which is the same format accepted by parseNetParamOverrides.
+func (o netParamOverrides) String() string {
+	names := make([]string, 0, len(o))
+	for name := range o {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+strconv.FormatUint(o[name], 10))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// parseNetParamOverrides parses a comma-separated list of name=value pairs
+// into chain parameter overrides.  The names are case insensitive and must
+// refer to a chain parameter that may be overridden.
+func parseNetParamOverrides(str string) (netParamOverrides, error) {
+	overrides := make(netParamOverrides)
+	if str == "" {
+		return overrides, nil
+	}
+	for _, pair := range strings.Split(str, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("chain parameter %q is not in "+
+				"the form name=value", pair)
+		}
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if !isNetParamName(name) {
+			return nil, fmt.Errorf("chain parameter %q may not be "+
+				"overridden", name)
+		}
+		value, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("chain parameter %q has an "+
+				"invalid value: %v", name, err)
+		}
+		overrides[name] = value
+	}
+	return overrides, nil
+}
+
+// newNetParams returns a copy of the chain parameters for the named network
+// with the provided overrides applied.  The overridden parameters are checked
+// for consistency since the simulator relies on them being sensible.
+func newNetParams(net string, overrides netParamOverrides) (*chaincfg.Params, error) {
+	netParams, ok := netParamsByName[net]
+	if !ok {
+		return nil, fmt.Errorf("%q is not a valid network -- available "+
+			"options: [%s]", net, strings.Join(netNames(), ", "))
+	}
+
+	params := *netParams
+	for name, value := range overrides {
+		if err := setNetParam(&params, name, value); err != nil {
+			return nil, fmt.Errorf("invalid chain parameter %q: %v",
+				name, err)
+		}
+	}
+
+	switch {
+	case params.TicketPoolSize == 0:
+		return nil, fmt.Errorf("ticket pool size must not be zero")
+	case params.TicketsPerBlock == 0:
+		return nil, fmt.Errorf("tickets per block must not be zero")
+	case params.StakeDiffWindowSize == 0:
+		return nil, fmt.Errorf("stake difficulty window size must not " +
+			"be zero")
+	case params.TicketExpiry == 0:
+		return nil, fmt.Errorf("ticket expiry must not be zero")
+	case params.TotalSubsidyProportions() == 0:
+		return nil, fmt.Errorf("subsidy proportions must not all be " +
+			"zero")
+	}
+	return &params, nil
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// TestNewNetParamsBounds ensures the chain parameter overrides are limited to
+// the values the types of the parameters, and the simulator, support.
+func TestNewNetParamsBounds(t *testing.T) {
+	tests := []struct {
+		name  string
+		value uint64
+		valid bool
+	}{
+		{name: "ticketpoolsize", value: math.MaxUint16, valid: true},
+		{name: "ticketpoolsize", value: math.MaxUint16 + 1},
+		{name: "ticketsperblock", value: math.MaxUint16, valid: true},
+		{name: "ticketsperblock", value: math.MaxUint16 + 1},
+		{name: "stakediffwindowsize", value: 1, valid: true},
+		{name: "stakediffwindowsize", value: math.MaxInt32, valid: true},
+		{name: "stakediffwindowsize", value: math.MaxInt32 + 1},
+		{name: "stakediffwindowsize", value: math.MaxInt64},
+		{name: "stakediffwindowsize", value: math.MaxUint64},
+		{name: "ticketmaturity", value: math.MaxUint16, valid: true},
+		{name: "ticketmaturity", value: math.MaxUint16 + 1},
+		{name: "ticketexpiry", value: math.MaxUint32, valid: true},
+		{name: "ticketexpiry", value: math.MaxUint32 + 1},
+		{name: "maxfreshstakeperblock", value: math.MaxUint8, valid: true},
+		{name: "maxfreshstakeperblock", value: math.MaxUint8 + 1},
+		{name: "workrewardproportion", value: math.MaxUint16, valid: true},
+		{name: "workrewardproportion", value: math.MaxUint16 + 1},
+		{name: "stakerewardproportion", value: math.MaxUint16, valid: true},
+		{name: "stakerewardproportion", value: math.MaxUint16 + 1},
+		{name: "blocktaxproportion", value: math.MaxUint16, valid: true},
+		{name: "blocktaxproportion", value: math.MaxUint16 + 1},
+	}
+
+	for _, test := range tests {
+		overrides := netParamOverrides{test.name: test.value}
+		params, err := newNetParams("mainnet", overrides)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s=%d: did not receive expected error",
+					test.name, test.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s=%d: unexpected error: %v", test.name,
+				test.value, err)
+			continue
+		}
+		if test.name == "stakediffwindowsize" &&
+			params.StakeDiffWindowSize != int64(test.value) {
+
+			t.Errorf("%s=%d: got window size %d", test.name,
+				test.value, params.StakeDiffWindowSize)
+		}
+	}
+}
+
+// TestNewNetParamsZero ensures the chain parameters the simulator divides by
+// may not be overridden to zero.
+func TestNewNetParamsZero(t *testing.T) {
+	names := []string{"ticketpoolsize", "ticketsperblock",
+		"stakediffwindowsize", "ticketexpiry"}
+	for _, name := range names {
+		_, err := newNetParams("mainnet", netParamOverrides{name: 0})
+		if err == nil {
+			t.Errorf("%s=0: did not receive expected error", name)
+		}
+	}
+}
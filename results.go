@@ -0,0 +1,407 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/davecgh/dcrstakesim/stakesim"
+	"github.com/decred/dcrutil"
+)
+
+// resultsColors are the colors used for the series of each simulation run in
+// the results charts.  They are reused when there are more runs than colors.
+var resultsColors = []string{"#0c1e3e", "#2972ff", "#2ed7a2", "#fd714a",
+	"#8e44ad", "#f1c40f", "#e74c3c", "#16a085"}
+
+// eventColors are the colors used to highlight the heights of each simulated
+// event in the results charts.  They are reused when there are more events
+// than colors.
+var eventColors = []string{"#ffff66", "#66ccff", "#ff9999", "#99ff99",
+	"#cc99ff"}
+
+// writeCSVFloat writes the passed value formatted with 8 decimal places to the
+// provided buffer preceded by a comma.
+func writeCSVFloat(buf *bytes.Buffer, value float64) {
+	buf.WriteRune(',')
+	buf.WriteString(strconv.FormatFloat(value, 'f', 8, 64))
+}
+
+// resultsParameter houses the name and value of a parameter of the simulation
+// runs that is shown in the results.
+type resultsParameter struct {
+	Name  string
+	Value string
+}
+
+// resultsParameters returns the parameters shown in the results of the group
+// the passed simulation run is a part of.  The seeds are shown as provided
+// since they differ for Monte Carlo runs.
+func resultsParameters(run *simRun, seeds string) []resultsParameter {
+	df, cfg := run.df, run.cfg
+	parameters := []resultsParameter{
+		{"Network", cfg.net},
+		{"Demand Distribution Function", df.Key + " - " + df.Description},
+		{"Seed", seeds},
+	}
+	if len(cfg.netOverrides) > 0 {
+		parameters = append(parameters, resultsParameter{
+			"Chain Parameter Overrides", cfg.netOverrides.String()})
+	}
+	if len(run.dfParams) > 0 {
+		parameters = append(parameters, resultsParameter{
+			"Demand Distribution Parameters", run.dfParams.String()})
+	}
+	nm := cfg.noiseModel
+	parameters = append(parameters, resultsParameter{"Demand Noise Model",
+		nm.Key + " - " + nm.Description})
+	if len(cfg.noiseParams) > 0 {
+		parameters = append(parameters, resultsParameter{
+			"Demand Noise Parameters", cfg.noiseParams.String()})
+	}
+	mm := cfg.missModel
+	parameters = append(parameters, resultsParameter{"Missed Vote Model",
+		mm.Key + " - " + mm.Description})
+	if len(cfg.missParams) > 0 {
+		parameters = append(parameters, resultsParameter{
+			"Missed Vote Parameters", cfg.missParams.String()})
+	}
+	rm := cfg.revokeModel
+	parameters = append(parameters, resultsParameter{"Revocation Model",
+		rm.Key + " - " + rm.Description})
+	if len(cfg.revokeParams) > 0 {
+		parameters = append(parameters, resultsParameter{
+			"Revocation Parameters", cfg.revokeParams.String()})
+	}
+	pp := cfg.purchaseProfile
+	parameters = append(parameters, resultsParameter{"Purchase Profile",
+		pp.Key + " - " + pp.Description})
+	if len(cfg.profileParams) > 0 {
+		parameters = append(parameters, resultsParameter{
+			"Purchase Profile Parameters", cfg.profileParams.String()})
+	}
+	if cfg.mempool {
+		parameters = append(parameters, resultsParameter{
+			"Ticket Mempool Parameters", cfg.mempoolParams.String()})
+	}
+	if cfg.numAgents > 0 {
+		parameters = append(parameters, resultsParameter{
+			"Stakeholder Agents", strconv.Itoa(cfg.numAgents)})
+		parameters = append(parameters, resultsParameter{
+			"Stakeholder Agent Parameters", cfg.agentParams.String()})
+	}
+	if cfg.splitTickets {
+		parameters = append(parameters, resultsParameter{
+			"Split Ticket Parameters", cfg.splitParams.String()})
+	}
+	for i, vsp := range cfg.vsps {
+		parameters = append(parameters, resultsParameter{
+			fmt.Sprintf("Voting Service Provider %d", i+1),
+			vsp.Description()})
+	}
+	if height := cfg.autoRevocationsHeight; height >= 0 {
+		parameters = append(parameters, resultsParameter{
+			"Automatic Revocations Height", strconv.Itoa(int(height))})
+	}
+	return parameters
+}
+
+// eventBand houses the details needed to highlight the heights of a simulated
+// event in the results charts.
+type eventBand struct {
+	Description string
+	Start       int32
+	End         int32
+	Color       string
+}
+
+// resultsEvents returns the bands which highlight the heights of each event
+// that was simulated by the passed simulator.  Each event is shown in a
+// separate band using its own color.
+func resultsEvents(s *stakesim.Simulator) []eventBand {
+	events := make([]eventBand, 0, len(s.ScheduledEvents()))
+	for i, event := range s.ScheduledEvents() {
+		events = append(events, eventBand{
+			Description: event.Description(),
+			Start:       event.StartHeight,
+			End:         event.EndHeight,
+			Color:       eventColors[i%len(eventColors)],
+		})
+	}
+	return events
+}
+
+// generateResults creates an HTML results file for the passed completed
+// simulation runs.  The charts overlay the results of all runs and the summary
+// table shows the metrics for each of them so they can be easily compared.
+//
+// The runs in the group are expected to have been performed with the same
+// demand distribution function, seed, and number of blocks.  The scenario which
+// reproduces them is embedded in the results.
+//
+// Groups of Monte Carlo runs are instead shown as percentile bands by
+// generateMonteCarloResults.
+func generateResults(group *runGroup) error {
+	if group.isMonteCarlo() {
+		return generateMonteCarloResults(group)
+	}
+	runs, resultsPath := group.runs, group.resultsPath
+
+	// Parse the results template.
+	resultsTpl, err := template.New("results").Parse(resultsTmplText)
+	if err != nil {
+		return fmt.Errorf("unable to parse results template: %v", err)
+	}
+	resultsFile, err := os.Create(resultsPath)
+	if err != nil {
+		return fmt.Errorf("unable to create results: %v", err)
+	}
+	defer resultsFile.Close()
+
+	// Shorter version of some params for convenience.
+	params := runs[0].sim.Params()
+	windowSize := int32(params.StakeDiffWindowSize)
+
+	// Generate the data needed for the HTML template and execute it in
+	// order to generate the final HTML results file.  Each line of the CSV
+	// data contains the values for all of the runs at a given height.
+	var poolSizeCSV, ticketPriceCSV, supplyCSV, mempoolCSV bytes.Buffer
+	hasMempool := runs[0].cfg.mempool
+	nodes := make([]*stakesim.BlockNode, len(runs))
+	for i, run := range runs {
+		nodes[i] = run.sim.Root()
+	}
+	for nodes[0] != nil {
+		heightStr := strconv.Itoa(int(nodes[0].Height()))
+		poolSizeCSV.WriteString(heightStr)
+		if hasMempool {
+			mempoolCSV.WriteString(heightStr)
+		}
+		supplyCSV.WriteString(heightStr)
+		supplyCSV.WriteRune(',')
+		supply := nodes[0].TotalSupply().ToCoin() / 1e6
+		supplyCSV.WriteString(strconv.FormatFloat(supply, 'f', 8, 64))
+		isRetarget := nodes[0].Height()%windowSize == 0
+		if isRetarget {
+			ticketPriceCSV.WriteString(heightStr)
+		}
+		for i, node := range nodes {
+			// Leave the values empty for any runs that do not have a
+			// block at the height.
+			if node == nil {
+				poolSizeCSV.WriteRune(',')
+				supplyCSV.WriteRune(',')
+				if hasMempool {
+					mempoolCSV.WriteRune(',')
+				}
+				if isRetarget {
+					ticketPriceCSV.WriteRune(',')
+				}
+				continue
+			}
+
+			poolSizeCSV.WriteRune(',')
+			poolSizeCSV.WriteString(strconv.FormatInt(int64(node.PoolSize()), 10))
+			if isRetarget {
+				price := dcrutil.Amount(node.TicketPrice()).ToCoin()
+				writeCSVFloat(&ticketPriceCSV, price)
+			}
+			writeCSVFloat(&supplyCSV, node.StakedCoins().ToCoin()/1e6)
+			if hasMempool {
+				mempoolCSV.WriteRune(',')
+				depth := int64(node.MempoolDepth())
+				mempoolCSV.WriteString(strconv.FormatInt(depth, 10))
+			}
+			nodes[i] = node.Next()
+		}
+		poolSizeCSV.WriteRune('\n')
+		if hasMempool {
+			mempoolCSV.WriteRune('\n')
+		}
+		supplyCSV.WriteRune('\n')
+		if isRetarget {
+			ticketPriceCSV.WriteRune('\n')
+		}
+	}
+
+	// Generate the per-run labels and metrics.
+	type runResults struct {
+		Name               string
+		MinTicketPrice     string
+		MaxTicketPrice     string
+		MeanTicketPrice    string
+		NumTickets         int
+		NumWinners         int
+		NumExpired         int
+		ExpiredPercent     string
+		MinPoolSize        uint32
+		MaxPoolSize        uint32
+		MeanPoolSize       string
+		PercentOutsideBand string
+		AvgStakedPercent   string
+		CoinSupply         string
+		SpendableSupply    string
+		SoloNetYield       string
+		PooledNetYield     string
+		SoloMissRate       string
+		PooledMissRate     string
+		PricedOut          string
+		Verification       string
+	}
+	runResultsList := make([]runResults, 0, len(runs))
+	seriesLabels := make([]string, 0, len(runs))
+	stakedLabels := make([]string, 0, len(runs))
+	colors := make([]string, 0, len(runs))
+	for i, run := range runs {
+		summary := run.summary
+		meanPrice := dcrutil.Amount(summary.TicketPrice.Mean)
+		avgStakedPercent := summary.AvgStakedFraction * 100
+		results := runResults{
+			Name:               run.pf.Description(),
+			MinTicketPrice:     dcrutil.Amount(summary.MinTicketPrice).String(),
+			MaxTicketPrice:     dcrutil.Amount(summary.MaxTicketPrice).String(),
+			MeanTicketPrice:    meanPrice.String(),
+			NumTickets:         summary.NumTickets,
+			NumWinners:         summary.NumWinners,
+			NumExpired:         summary.NumExpired,
+			ExpiredPercent:     strconv.FormatFloat(summary.ExpiredPercent, 'f', 2, 64),
+			MinPoolSize:        summary.MinPoolSize,
+			MaxPoolSize:        summary.MaxPoolSize,
+			MeanPoolSize:       strconv.FormatFloat(summary.PoolSize.Mean, 'f', 0, 64),
+			PercentOutsideBand: strconv.FormatFloat(summary.PercentOutsideBand, 'f', 2, 64),
+			AvgStakedPercent:   strconv.FormatFloat(avgStakedPercent, 'f', 2, 64),
+			CoinSupply:         run.sim.Tip().TotalSupply().String(),
+			SpendableSupply:    run.sim.Tip().SpendableSupply().String(),
+		}
+		if summary.Solo != nil && summary.Pooled != nil {
+			formatPercent := func(v float64) string {
+				return strconv.FormatFloat(v*100, 'f', 2, 64)
+			}
+			results.SoloNetYield = formatPercent(summary.Solo.NetYield)
+			results.PooledNetYield = formatPercent(summary.Pooled.NetYield)
+			results.SoloMissRate = formatPercent(summary.Solo.MissRate)
+			results.PooledMissRate = formatPercent(summary.Pooled.MissRate)
+		}
+		if run.cfg.numAgents > 0 {
+			pricedOut := float64(summary.PricedOutStakeholders) * 100 /
+				float64(run.cfg.numAgents)
+			results.PricedOut = fmt.Sprintf("%d (%s%%), %d",
+				summary.PricedOutStakeholders,
+				strconv.FormatFloat(pricedOut, 'f', 2, 64),
+				summary.SplitTickets)
+		}
+		if summary.Verify != nil {
+			results.Verification = summary.Verify.String()
+		}
+		runResultsList = append(runResultsList, results)
+
+		// Use generic labels when there is only a single run to match
+		// the chart titles.
+		seriesLabel, stakedLabel := "Pool Size", "Staked Supply"
+		if len(runs) > 1 {
+			seriesLabel = run.pf.Name
+			stakedLabel = "Staked Supply (" + run.pf.Name + ")"
+		}
+		seriesLabels = append(seriesLabels, seriesLabel)
+		stakedLabels = append(stakedLabels, stakedLabel)
+		colors = append(colors, resultsColors[i%len(resultsColors)])
+	}
+	ticketPriceLabels, mempoolLabels := seriesLabels, seriesLabels
+	poolSizeColors, ticketPriceColors := colors, colors
+	supplyColors := append([]string{"#fd714a"}, colors...)
+	if len(runs) == 1 {
+		ticketPriceLabels = []string{"Ticket Price"}
+		mempoolLabels = []string{"Mempool Depth"}
+		poolSizeColors = []string{"#0c1e3e"}
+		ticketPriceColors = []string{"#2972ff"}
+		supplyColors = []string{"#0c1e3e", "#2972ff"}
+	}
+
+	seed := strconv.FormatInt(runs[0].seed, 10)
+	parameters := resultsParameters(runs[0], seed)
+	events := resultsEvents(runs[0].sim)
+
+	scenarioJSON, err := json.MarshalIndent(groupScenario(group), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = resultsTpl.Execute(resultsFile, map[string]interface{}{
+		"PoolSizeCSV":       poolSizeCSV.String(),
+		"TicketPriceCSV":    ticketPriceCSV.String(),
+		"SupplyCSV":         supplyCSV.String(),
+		"MempoolCSV":        mempoolCSV.String(),
+		"Runs":              runResultsList,
+		"NumRuns":           len(runs),
+		"VotingServices":    len(runs[0].cfg.vsps) > 0,
+		"Agents":            runs[0].cfg.numAgents > 0,
+		"Verify":            runs[0].cfg.verify,
+		"PoolSizeBand":      runs[0].summary.PoolSizeBand,
+		"PoolSizeLabels":    append([]string{"Block"}, seriesLabels...),
+		"TicketPriceLabels": append([]string{"Block"}, ticketPriceLabels...),
+		"SupplyLabels":      append([]string{"Block", "Total Supply"}, stakedLabels...),
+		"MempoolLabels":     append([]string{"Block"}, mempoolLabels...),
+		"PoolSizeColors":    poolSizeColors,
+		"TicketPriceColors": ticketPriceColors,
+		"SupplyColors":      supplyColors,
+		"FillGraph":         len(runs) == 1,
+		"CustomBars":        false,
+		"Parameters":        parameters,
+		"Events":            events,
+		"Scenario":          string(scenarioJSON),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to execute template: %v", err)
+	}
+
+	return nil
+}
+
+// generateIndex generates an HTML index at the provided path which links to the
+// results of each of the passed groups of simulation runs along with a few key
+// metrics of every run so they can be compared at a glance.
+func generateIndex(groups []*runGroup, indexPath string) error {
+	indexTpl, err := template.New("index").Parse(indexTmplText)
+	if err != nil {
+		return fmt.Errorf("unable to parse index template: %v", err)
+	}
+	indexFile, err := os.Create(indexPath)
+	if err != nil {
+		return fmt.Errorf("unable to create index: %v", err)
+	}
+	defer indexFile.Close()
+
+	// The results of each group are written alongside the index, so link
+	// to them relative to it.
+	type indexGroup struct {
+		Link string
+		Runs []*stakesim.Summary
+	}
+	indexGroups := make([]indexGroup, 0, len(groups))
+	for _, group := range groups {
+		summaries := make([]*stakesim.Summary, 0, len(group.runs))
+		for _, run := range group.runs {
+			summaries = append(summaries, run.summary)
+		}
+		indexGroups = append(indexGroups, indexGroup{
+			Link: filepath.Base(group.resultsPath),
+			Runs: summaries,
+		})
+	}
+	err = indexTpl.Execute(indexFile, map[string]interface{}{
+		"Groups": indexGroups,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to execute index template: %v", err)
+	}
+
+	return nil
+}
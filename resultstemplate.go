@@ -54,55 +54,125 @@ var resultsTmplText = `
       </div>
       <div style="width: 95%;">
         <table>
+          {{if .Distributions}}
+          <tr>
+            <td>Price Function</td>
+            {{range .PriceFuncNames}}<td>{{.}}</td>{{end}}
+          </tr>
+          {{range .Distributions}}
+          <tr>
+            <td>{{.Name}}</td>
+            {{range .Values}}<td>{{.}}</td>{{end}}
+          </tr>
+          {{end}}
+          {{else}}
+          <tr>
+            <td>Price Function</td>
+            {{range .Runs}}<td>{{.Name}}</td>{{end}}
+          </tr>
           <tr>
             <td>Min & Max Ticket Price</td>
-            <td>{{.MinTicketPrice}}, {{.MaxTicketPrice}}</td>
+            {{range .Runs}}<td>{{.MinTicketPrice}}, {{.MaxTicketPrice}}</td>{{end}}
+          </tr>
+          <tr>
+            <td>Mean Ticket Price</td>
+            {{range .Runs}}<td>{{.MeanTicketPrice}}</td>{{end}}
           </tr>
           <tr>
             <td>Total, Winning, & Expired Tickets</td>
-            <td>{{.NumTickets}}, {{.NumWinners}}, {{.NumExpired}} ({{.ExpiredPercent}}%)</td>
+            {{range .Runs}}<td>{{.NumTickets}}, {{.NumWinners}}, {{.NumExpired}} ({{.ExpiredPercent}}%)</td>{{end}}
           </tr>
           <tr>
-            <td>Min & Max Pool Size</td>
-            <td>{{.MinPoolSize}}, {{.MaxPoolSize}}</td>
+            <td>Min, Max, & Mean Pool Size</td>
+            {{range .Runs}}<td>{{.MinPoolSize}}, {{.MaxPoolSize}}, {{.MeanPoolSize}}</td>{{end}}
+          </tr>
+          <tr>
+            <td>Blocks With Pool Size Outside &plusmn;{{.PoolSizeBand}}% of Target</td>
+            {{range .Runs}}<td>{{.PercentOutsideBand}}%</td>{{end}}
+          </tr>
+          <tr>
+            <td>Average Staked Supply</td>
+            {{range .Runs}}<td>{{.AvgStakedPercent}}%</td>{{end}}
           </tr>
           <tr>
             <td>Total & Spendable Coin Supply</td>
-            <td>{{.CoinSupply}}, {{.SpendableSupply}}</td>
+            {{range .Runs}}<td>{{.CoinSupply}}, {{.SpendableSupply}}</td>{{end}}
+          </tr>
+          {{if .Verify}}
+          <tr>
+            <td>Header Verification</td>
+            {{range .Runs}}<td>{{.Verification}}</td>{{end}}
+          </tr>
+          {{end}}
+          {{if .Agents}}
+          <tr>
+            <td>Priced Out Stakeholders & Split Tickets</td>
+            {{range .Runs}}<td>{{.PricedOut}}</td>{{end}}
           </tr>
+          {{end}}
+          {{if .VotingServices}}
+          <tr>
+            <td>Net Yield of Solo & Pooled Tickets</td>
+            {{range .Runs}}<td>{{.SoloNetYield}}%, {{.PooledNetYield}}%</td>{{end}}
+          </tr>
+          <tr>
+            <td>Missed Votes of Solo & Pooled Tickets</td>
+            {{range .Runs}}<td>{{.SoloMissRate}}%, {{.PooledMissRate}}%</td>{{end}}
+          </tr>
+          {{end}}
+          {{end}}
           {{range .Parameters}}
           <tr>
             <td>{{.Name}}</td>
-            <td>{{.Value}}</td>
+            <td colspan="{{$.NumRuns}}">{{.Value}}</td>
+          </tr>
+          {{end}}
+          {{range .Events}}
+          <tr>
+            <td>Event <span style="background-color: {{.Color}};">&nbsp;&nbsp;&nbsp;&nbsp;</span></td>
+            <td colspan="{{$.NumRuns}}">{{.Description}} from height {{.Start}} to {{.End}}</td>
           </tr>
           {{end}}
           <tr>
             <td>Notes</td>
-            <td>
-              Left click and drag to zoom.  Shift+Click to pan.  Yellow highlight
-              (if present) specifies the heights in between which a simulated
-              surge of extra coins to stake became available and after which
-              were taken away.
+            <td colspan="{{.NumRuns}}">
+              Left click and drag to zoom.  Shift+Click to pan.  Highlighted
+              bands (if present) specify the heights in between which each of
+              the simulated events listed above were active.  Each event is
+              shown in its own band using the color listed next to it.
+              {{if .CustomBars}}
+              The metrics above are the median across all of the Monte Carlo
+              runs followed by the 5th and 95th percentiles.  Likewise, each
+              line in the charts is the median at every height and the shaded
+              area around it spans the 5th to 95th percentiles.
+              {{end}}
             </td>
           </tr>
         </table>
+        <details style="text-align: left;">
+          <summary>Scenario</summary>
+          <pre>{{.Scenario}}</pre>
+        </details>
       </div>
       <div id="charts" style="width: 95%; text-align: center;">
         <div id="poolsizediv" style="width: 50%; float: left;"></div>
         <div id="ticketpricediv" style="width: 50%; float: right;"></div>
         <div id="supplydiv" style="width: 50%; float: left;"></div>
+        {{if .MempoolCSV}}<div id="mempooldiv" style="width: 50%; float: right;"></div>{{end}}
       </div>
     </div>
 
     <script>
+      var events = {{.Events}};
       function highlight(canvas, area, g) {
-        if ({{.SurgeUpHeight}} == 0) { return; }
-        var bottomLeft = g.toDomCoords({{.SurgeUpHeight}});
-        var topRight = g.toDomCoords({{.SurgeDownHeight}});
-        var left = bottomLeft[0];
-        var right = topRight[0];
-        canvas.fillStyle = "rgba(255, 255, 102, 1.0)";
-        canvas.fillRect(left, area.y, right - left, area.h);
+        var bandHeight = area.h / events.length;
+        for (var i = 0; i < events.length; i++) {
+          var left = g.toDomCoords(events[i].Start)[0];
+          var right = g.toDomCoords(events[i].End)[0];
+          canvas.fillStyle = events[i].Color;
+          canvas.fillRect(left, area.y + i * bandHeight, right - left,
+            bandHeight);
+        }
       }
 
       window.onload = function() {
@@ -110,12 +180,13 @@ var resultsTmplText = `
         var poolSizeGraph = new Dygraph(document.getElementById("poolsizediv"), csv,
           {
             title: 'Pool Size Per Block',
-            labels: ['Block','Pool Size'],
+            labels: {{.PoolSizeLabels}},
             xlabel: 'Block Height',
             ylabel: 'Pool Size',
             legend: 'always',
-            colors: ['#0c1e3e'],
-            fillGraph: true,
+            colors: {{.PoolSizeColors}},
+            fillGraph: {{.FillGraph}},
+            customBars: {{.CustomBars}},
             animatedZooms: true,
             underlayCallback: highlight,
             plugins : [
@@ -128,12 +199,13 @@ var resultsTmplText = `
         var ticketPriceGraph = new Dygraph(document.getElementById("ticketpricediv"), csv,
           {
             title: 'Ticket Price Per Retarget Interval',
-            labels: ['Block','Ticket Price'],
+            labels: {{.TicketPriceLabels}},
             xlabel: 'Block Height',
             ylabel: 'Ticket Price',
             legend: 'always',
-            colors: ['#2972ff'],
-            fillGraph: true,
+            colors: {{.TicketPriceColors}},
+            fillGraph: {{.FillGraph}},
+            customBars: {{.CustomBars}},
             drawPoints: true,
             animatedZooms: true,
             underlayCallback: highlight,
@@ -147,12 +219,13 @@ var resultsTmplText = `
         var supplyGraph = new Dygraph(document.getElementById("supplydiv"), csv,
           {
             title: 'Supply Per Block',
-            labels: ['Block','Total Supply','Staked Supply'],
+            labels: {{.SupplyLabels}},
             xlabel: 'Block Height',
             ylabel: 'Millions of DCR',
             legend: 'always',
-            colors: ['#0c1e3e','#2972ff'],
-            fillGraph: true,
+            colors: {{.SupplyColors}},
+            fillGraph: {{.FillGraph}},
+            customBars: {{.CustomBars}},
             drawPoints: true,
             animatedZooms: true,
             underlayCallback: highlight,
@@ -161,8 +234,81 @@ var resultsTmplText = `
             ]
           }
         );
+        {{if .MempoolCSV}}
+        var csv = "{{.MempoolCSV}}";
+        var mempoolGraph = new Dygraph(document.getElementById("mempooldiv"), csv,
+          {
+            title: 'Ticket Mempool Depth Per Block',
+            labels: {{.MempoolLabels}},
+            xlabel: 'Block Height',
+            ylabel: 'Waiting Purchases',
+            legend: 'always',
+            colors: {{.PoolSizeColors}},
+            fillGraph: {{.FillGraph}},
+            customBars: {{.CustomBars}},
+            animatedZooms: true,
+            underlayCallback: highlight,
+            plugins : [
+                Dygraph.Plugins.Unzoom
+            ]
+          }
+        );
+        {{end}}
       }
     </script>
   </body>
 </html>
 `
+
+var indexTmplText = `
+<!doctype html>
+<html lang="en">
+  <head>
+    <meta charset="utf-8">
+    <title>Simulation Results Index</title>
+    <style>
+      body { font-family: sans-serif; }
+      table { border-collapse: collapse; }
+      th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+      th { background-color: #eee; }
+    </style>
+  </head>
+  <body>
+    <h1>Simulation Results</h1>
+    <table>
+      <tr>
+        <th>Results</th>
+        <th>Price Func</th>
+        <th>Demand Func</th>
+        <th>Seed</th>
+        <th>Height</th>
+        <th>Min Ticket Price (atoms)</th>
+        <th>Max Ticket Price (atoms)</th>
+        <th>Min Pool Size</th>
+        <th>Max Pool Size</th>
+        <th>% Outside Band</th>
+        <th>Avg Vote Wait (days)</th>
+        <th>Expired %</th>
+        <th>Duration (s)</th>
+      </tr>
+      {{range .Groups}}{{$link := .Link}}{{range .Runs}}
+      <tr>
+        <td><a href="{{$link}}">{{$link}}</a></td>
+        <td>{{.PriceFunc}}</td>
+        <td>{{.DemandFunc}}</td>
+        <td>{{.Seed}}</td>
+        <td>{{.Height}}</td>
+        <td>{{.MinTicketPrice}}</td>
+        <td>{{.MaxTicketPrice}}</td>
+        <td>{{.MinPoolSize}}</td>
+        <td>{{.MaxPoolSize}}</td>
+        <td>{{printf "%.2f" .PercentOutsideBand}}</td>
+        <td>{{printf "%.2f" .AvgVoteWaitDays}}</td>
+        <td>{{printf "%.2f" .ExpiredPercent}}</td>
+        <td>{{printf "%.2f" .Duration}}</td>
+      </tr>
+      {{end}}{{end}}
+    </table>
+  </body>
+</html>
+`
@@ -0,0 +1,352 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davecgh/dcrstakesim/stakesim"
+	"github.com/decred/dcrd/chaincfg"
+)
+
+// simRun houses a simulator configured with a specific combination of ticket
+// price function, demand distribution function, seed, and number of blocks
+// along with a summary of the results once it has been run and the error that
+// stopped it, if any.
+type simRun struct {
+	sim       *stakesim.Simulator
+	cfg       *simConfig
+	pf        *stakesim.PriceFunc
+	df        *stakesim.DemandFunc
+	dfParams  stakesim.ModelParams
+	seed      int64
+	numBlocks uint64
+	summary   *stakesim.Summary
+	err       error
+}
+
+// simConfig houses the simulator configuration that is shared by all of the
+// simulation runs.
+type simConfig struct {
+	net                   string
+	netOverrides          netParamOverrides
+	params                *chaincfg.Params
+	stakeCap              float64
+	events                []stakesim.Event
+	missModel             *stakesim.MissModel
+	missParams            stakesim.ModelParams
+	revokeModel           *stakesim.RevocationModel
+	revokeParams          stakesim.ModelParams
+	noiseModel            *stakesim.NoiseModel
+	noiseParams           stakesim.ModelParams
+	purchaseProfile       *stakesim.PurchaseProfile
+	profileParams         stakesim.ModelParams
+	mempool               bool
+	mempoolParams         stakesim.ModelParams
+	numAgents             int
+	agentParams           stakesim.ModelParams
+	splitTickets          bool
+	splitParams           stakesim.ModelParams
+	vsps                  []*stakesim.VotingService
+	verify                bool
+	autoRevocationsHeight int32
+	monteCarloRuns        int
+	verbose               bool
+}
+
+// newSimRun returns a new simulation run which uses the provided ticket price
+// function and demand distribution function configured with the given
+// parameters along with the shared simulator configuration.  The seed is used
+// to vary the simulation and the number of blocks is the number of blocks to
+// simulate when not using CSV data.
+func newSimRun(pf *stakesim.PriceFunc, df *stakesim.DemandFunc, dfParams stakesim.ModelParams, seed int64, numBlocks uint64, cfg *simConfig) (*simRun, error) {
+	var log io.Writer
+	if cfg.verbose {
+		log = os.Stdout
+	}
+	sim, err := stakesim.New(&stakesim.Config{
+		Params:                cfg.params,
+		Seed:                  seed,
+		StakeCap:              cfg.stakeCap,
+		AutoRevocations:       cfg.autoRevocationsHeight >= 0,
+		AutoRevocationsHeight: cfg.autoRevocationsHeight,
+		Events:                cfg.events,
+		PriceFunc:             pf,
+		DemandFunc:            df,
+		DemandParams:          dfParams,
+		PurchaseProfile:       cfg.purchaseProfile,
+		ProfileParams:         cfg.profileParams,
+		NoiseModel:            cfg.noiseModel,
+		NoiseParams:           cfg.noiseParams,
+		MissModel:             cfg.missModel,
+		MissParams:            cfg.missParams,
+		RevocationModel:       cfg.revokeModel,
+		RevocationParams:      cfg.revokeParams,
+		Mempool:               cfg.mempool,
+		MempoolParams:         cfg.mempoolParams,
+		NumAgents:             cfg.numAgents,
+		AgentParams:           cfg.agentParams,
+		SplitTickets:          cfg.splitTickets,
+		SplitParams:           cfg.splitParams,
+		VSPs:                  cfg.vsps,
+		Verify:                cfg.verify,
+		Log:                   log,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &simRun{
+		sim:       sim,
+		cfg:       cfg,
+		pf:        pf,
+		df:        df,
+		dfParams:  dfParams,
+		seed:      seed,
+		numBlocks: numBlocks,
+	}, nil
+}
+
+// String returns a human-readable description of the run configuration.
+func (r *simRun) String() string {
+	return fmt.Sprintf("price func %s, demand func %s, seed %d, %d blocks",
+		r.pf.Key, r.df.Key, r.seed, r.numBlocks)
+}
+
+// groupKey returns an identifier for the configuration of the run excluding
+// the ticket price function, and also the seed when performing Monte Carlo
+// runs.  Runs with the same group key are compared with one another in the
+// same results.
+func (r *simRun) groupKey() string {
+	if r.cfg.monteCarloRuns > 1 {
+		return fmt.Sprintf("ddf%s-blocks%d", r.df.Key, r.numBlocks)
+	}
+	return fmt.Sprintf("ddf%s-seed%d-blocks%d", r.df.Key, r.seed,
+		r.numBlocks)
+}
+
+// pathSuffix returns a suffix which uniquely identifies the run configuration
+// and is suitable for use in file names.
+func (r *simRun) pathSuffix() string {
+	return fmt.Sprintf("pf%s-ddf%s-seed%d-blocks%d", r.pf.Key, r.df.Key,
+		r.seed, r.numBlocks)
+}
+
+// simulate runs the simulation using the provided CSV data or the demand
+// distribution function when no CSV path is provided.  A panic during the
+// simulation, such as from a misbehaving ticket price function, is recovered
+// and returned as an error so it only fails this run instead of the entire
+// batch.
+func (r *simRun) simulate(csvPath string) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("simulation panicked: %v", p)
+		}
+	}()
+
+	if csvPath != "" {
+		return r.sim.SimulateFromCSV(csvPath)
+	}
+	return r.sim.Simulate(r.numBlocks)
+}
+
+// execute runs the simulation using the provided CSV data or the demand
+// distribution function when no CSV path is provided and then summarizes the
+// results.
+//
+// When the simulation fails, the error is recorded in the run and returned and
+// the summary only identifies the run along with the error and the height of
+// the last block that was simulated.
+func (r *simRun) execute(csvPath string, poolSizeBand float64) error {
+	startTime := time.Now()
+	err := r.simulate(csvPath)
+	simDuration := time.Since(startTime)
+
+	if err != nil {
+		r.err = err
+		r.summary = &stakesim.Summary{Error: err.Error()}
+		if serr, ok := err.(stakesim.SimError); ok {
+			r.summary.ErrorCode = serr.ErrorCode.String()
+		}
+		if r.sim.Tip() != nil {
+			r.summary.Height = r.sim.Tip().Height()
+		}
+	} else {
+		r.summary = stakesim.Summarize(r.sim, poolSizeBand)
+	}
+	r.summary.PriceFunc = r.pf.Key
+	r.summary.DemandFunc = r.df.Key
+	r.summary.Seed = r.seed
+	r.summary.Duration = simDuration.Seconds()
+	return err
+}
+
+// runGroup houses simulation runs that only differ by their ticket price
+// function, and also their seed when performing Monte Carlo runs, along with
+// the path to the results that compare them.  The distributions of the summary
+// metrics of each ticket price function are only calculated for Monte Carlo
+// runs.
+type runGroup struct {
+	key           string
+	runs          []*simRun
+	resultsPath   string
+	distributions []*monteCarloDistribution
+}
+
+// isMonteCarlo returns whether or not the group consists of Monte Carlo runs.
+func (g *runGroup) isMonteCarlo() bool {
+	return g.runs[0].cfg.monteCarloRuns > 1
+}
+
+// runsByPriceFunc returns the runs of the group split by their ticket price
+// function in the order the price functions first appear.
+func (g *runGroup) runsByPriceFunc() [][]*simRun {
+	var pfRuns [][]*simRun
+	indexByKey := make(map[string]int)
+	for _, run := range g.runs {
+		i, ok := indexByKey[run.pf.Key]
+		if !ok {
+			i = len(pfRuns)
+			indexByKey[run.pf.Key] = i
+			pfRuns = append(pfRuns, nil)
+		}
+		pfRuns[i] = append(pfRuns[i], run)
+	}
+	return pfRuns
+}
+
+// summarizeDistributions calculates the distributions of the summary metrics
+// of each ticket price function across the completed Monte Carlo runs of the
+// group.
+func (g *runGroup) summarizeDistributions() {
+	pfRuns := g.runsByPriceFunc()
+	g.distributions = make([]*monteCarloDistribution, 0, len(pfRuns))
+	for _, runs := range pfRuns {
+		g.distributions = append(g.distributions, summarizeMonteCarlo(runs))
+	}
+}
+
+// groupRuns groups the passed runs by their configuration excluding the ticket
+// price function while maintaining their order.
+func groupRuns(runs []*simRun) []*runGroup {
+	var groups []*runGroup
+	groupsByKey := make(map[string]*runGroup)
+	for _, run := range runs {
+		key := run.groupKey()
+		group, ok := groupsByKey[key]
+		if !ok {
+			group = &runGroup{key: key}
+			groupsByKey[key] = group
+			groups = append(groups, group)
+		}
+		group.runs = append(group.runs, run)
+	}
+	return groups
+}
+
+// runPath returns the passed path with the provided suffix inserted before the
+// file extension.  It is used to derive unique paths for each simulation run
+// when multiple runs are performed.
+func runPath(path, suffix string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + suffix + ext
+}
+
+// executeRuns performs all of the passed simulation runs using a pool of the
+// given number of workers so that multiple simulations run concurrently across
+// the available CPU cores.
+//
+// The progress of each individual simulation is only reported when there is a
+// single run since the output would otherwise be interleaved.  Instead, a line
+// is printed as each run completes.
+//
+// Runs that fail do not stop the others.  Their errors are recorded in the
+// runs and an error is only returned when every run fails.
+func executeRuns(runs []*simRun, csvPath string, poolSizeBand float64, numWorkers int) error {
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	if numWorkers > len(runs) {
+		numWorkers = len(runs)
+	}
+
+	// Report the progress of the simulation directly when there is only a
+	// single run.
+	if len(runs) == 1 {
+		run := runs[0]
+		if csvPath != "" {
+			fmt.Printf("Running simulation from %q, price func "+
+				"%s.\n", csvPath, run.pf.Key)
+		} else {
+			fmt.Printf("Running simulation for %d blocks, price "+
+				"func %s, demand func %s.\n", run.numBlocks,
+				run.pf.Key, run.df.Key)
+		}
+		fmt.Printf("Height")
+		run.sim.SetProgress(os.Stdout)
+		if err := run.execute(csvPath, poolSizeBand); err != nil {
+			fmt.Println()
+			return err
+		}
+		fmt.Println("..done")
+		fmt.Printf("Simulation took %v\n",
+			time.Duration(run.summary.Duration*float64(time.Second)))
+		return nil
+	}
+
+	fmt.Printf("Running %d simulations using %d workers.\n", len(runs),
+		numWorkers)
+	startTime := time.Now()
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+	var firstErr error
+	var numCompleted, numFailed int
+	runChan := make(chan *simRun)
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for run := range runChan {
+				err := run.execute(csvPath, poolSizeBand)
+
+				mtx.Lock()
+				numCompleted++
+				if err != nil {
+					numFailed++
+					if firstErr == nil {
+						firstErr = fmt.Errorf("%s: %v",
+							run, err)
+					}
+					fmt.Printf("[%d/%d] Failed %s: %v\n",
+						numCompleted, len(runs), run,
+						err)
+				} else {
+					fmt.Printf("[%d/%d] Completed %s in "+
+						"%.2fs\n", numCompleted,
+						len(runs), run,
+						run.summary.Duration)
+				}
+				mtx.Unlock()
+			}
+		}()
+	}
+	for _, run := range runs {
+		runChan <- run
+	}
+	close(runChan)
+	wg.Wait()
+	fmt.Println("Simulations took", time.Since(startTime))
+
+	if numFailed == len(runs) {
+		return fmt.Errorf("all %d simulations failed, first error: %v",
+			numFailed, firstErr)
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/davecgh/dcrstakesim/stakesim"
+)
+
+// scenario describes a full simulation setup so that it can be loaded from a
+// file with the -scenario flag and embedded in the generated results for
+// reproducibility.  Every field is optional and corresponds to a command line
+// flag.  Flags that are explicitly provided on the command line take
+// precedence over the values in the scenario.
+//
+// Only JSON is supported since it does not require any additional
+// dependencies.
+type scenario struct {
+	// Net is the name of the network whose parameters are simulated and
+	// NetParams overrides individual chain parameters of that network.
+	Net       string            `json:"net,omitempty"`
+	NetParams netParamOverrides `json:"netParams,omitempty"`
+
+	// PriceFuncs and DemandFuncs are the keys of the ticket price and
+	// demand distribution functions to simulate and DemandParams overrides
+	// the default parameters of the demand distribution functions.
+	PriceFuncs   []string             `json:"priceFuncs,omitempty"`
+	DemandFuncs  []string             `json:"demandFuncs,omitempty"`
+	DemandParams stakesim.ModelParams `json:"demandParams,omitempty"`
+
+	// StakeCap is the maximum fraction of the total supply that will be
+	// staked outside of any events that change it.
+	StakeCap *float64 `json:"stakeCap,omitempty"`
+
+	// NoiseModel is the key of the model for random noise applied to the
+	// demand and NoiseParams overrides the default parameters of the model.
+	NoiseModel  string               `json:"noiseModel,omitempty"`
+	NoiseParams stakesim.ModelParams `json:"noiseParams,omitempty"`
+
+	// MissModel is the key of the model for missed votes and MissParams
+	// overrides the default parameters of the model.
+	MissModel  string               `json:"missModel,omitempty"`
+	MissParams stakesim.ModelParams `json:"missParams,omitempty"`
+
+	// RevokeModel is the key of the model for revoking missed and expired
+	// tickets and RevokeParams overrides the default parameters of the
+	// model.
+	RevokeModel  string               `json:"revokeModel,omitempty"`
+	RevokeParams stakesim.ModelParams `json:"revokeParams,omitempty"`
+
+	// PurchaseProfile is the key of the profile which distributes the
+	// purchases demanded for each window across its blocks and
+	// ProfileParams overrides the default parameters of the profile.
+	PurchaseProfile string               `json:"purchaseProfile,omitempty"`
+	ProfileParams   stakesim.ModelParams `json:"profileParams,omitempty"`
+
+	// Mempool enables the simulated ticket mempool and MempoolParams
+	// overrides the default parameters of it.
+	Mempool       bool                 `json:"mempool,omitempty"`
+	MempoolParams stakesim.ModelParams `json:"mempoolParams,omitempty"`
+
+	// Agents is the number of stakeholder agents which decide the ticket
+	// purchases instead of the demand distribution functions and
+	// AgentParams overrides the default parameters of the agents.
+	Agents      int                  `json:"agents,omitempty"`
+	AgentParams stakesim.ModelParams `json:"agentParams,omitempty"`
+
+	// SplitTickets allows the agents that cannot afford a full ticket to
+	// pool their balances into split tickets and SplitParams overrides the
+	// default parameters of them.
+	SplitTickets bool                 `json:"splitTickets,omitempty"`
+	SplitParams  stakesim.ModelParams `json:"splitParams,omitempty"`
+
+	// VSPs are the voting service providers that purchased tickets are
+	// delegated to.  The remaining tickets are held by solo stakers.
+	VSPs []*stakesim.VotingService `json:"vsps,omitempty"`
+
+	// AutoRevocationsHeight is the height at which missed and expired
+	// tickets start being automatically revoked per DCP0009.
+	AutoRevocationsHeight *int32 `json:"autoRevocationsHeight,omitempty"`
+
+	// Events are the timed events which change ticket purchasing during
+	// the simulation.  An empty list disables the default events.
+	Events *[]stakesim.Event `json:"events,omitempty"`
+
+	// NumBlocks and Seeds are the number of blocks to simulate and the
+	// seeds used to vary the simulation.  Runs is the number of Monte Carlo
+	// runs which use consecutive seeds starting at the only seed.
+	NumBlocks []uint64 `json:"numBlocks,omitempty"`
+	Seeds     []int64  `json:"seeds,omitempty"`
+	Runs      int      `json:"runs,omitempty"`
+}
+
+// loadScenario loads and validates the scenario in the JSON file at the
+// provided path.  Unknown fields are rejected so that typos are not silently
+// ignored.
+func loadScenario(path string) (*scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sc scenario
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&sc); err != nil {
+		return nil, fmt.Errorf("unable to parse scenario %q: %v", path,
+			err)
+	}
+	return &sc, nil
+}
+
+// apply sets the flags that correspond to the fields present in the scenario
+// unless they were explicitly provided on the command line.  It must only be
+// called after the flags have been parsed.
+func (sc *scenario) apply() error {
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	setFlag := func(name, value string) error {
+		if explicit[name] {
+			return nil
+		}
+		return flag.Set(name, value)
+	}
+
+	var values []struct{ name, value string }
+	add := func(name, value string) {
+		values = append(values, struct{ name, value string }{name, value})
+	}
+	if sc.Net != "" {
+		add("net", sc.Net)
+	}
+	if len(sc.NetParams) > 0 {
+		add("netparams", sc.NetParams.String())
+	}
+	if len(sc.PriceFuncs) > 0 {
+		add("pf", strings.Join(sc.PriceFuncs, ","))
+	}
+	if len(sc.DemandFuncs) > 0 {
+		add("ddf", strings.Join(sc.DemandFuncs, ","))
+	}
+	if len(sc.DemandParams) > 0 {
+		add("ddfparams", sc.DemandParams.String())
+	}
+	if sc.NoiseModel != "" {
+		add("noise", sc.NoiseModel)
+	}
+	if len(sc.NoiseParams) > 0 {
+		add("noiseparams", sc.NoiseParams.String())
+	}
+	if sc.MissModel != "" {
+		add("missmodel", sc.MissModel)
+	}
+	if len(sc.MissParams) > 0 {
+		add("missparams", sc.MissParams.String())
+	}
+	if sc.RevokeModel != "" {
+		add("revokemodel", sc.RevokeModel)
+	}
+	if len(sc.RevokeParams) > 0 {
+		add("revokeparams", sc.RevokeParams.String())
+	}
+	if sc.PurchaseProfile != "" {
+		add("purchaseprofile", sc.PurchaseProfile)
+	}
+	if len(sc.ProfileParams) > 0 {
+		add("profileparams", sc.ProfileParams.String())
+	}
+	if sc.Mempool {
+		add("mempool", "true")
+	}
+	if len(sc.MempoolParams) > 0 {
+		add("mempoolparams", sc.MempoolParams.String())
+	}
+	if sc.Agents > 0 {
+		add("agents", strconv.Itoa(sc.Agents))
+	}
+	if len(sc.AgentParams) > 0 {
+		add("agentparams", sc.AgentParams.String())
+	}
+	if sc.SplitTickets {
+		add("splittickets", "true")
+	}
+	if len(sc.SplitParams) > 0 {
+		add("splitparams", sc.SplitParams.String())
+	}
+	if len(sc.VSPs) > 0 {
+		vspStrs := make([]string, 0, len(sc.VSPs))
+		for _, vsp := range sc.VSPs {
+			vspStrs = append(vspStrs, vsp.String())
+		}
+		add("vsps", strings.Join(vspStrs, ","))
+	}
+	if sc.AutoRevocationsHeight != nil {
+		height := int64(*sc.AutoRevocationsHeight)
+		add("autorevocations", strconv.FormatInt(height, 10))
+	}
+	if sc.StakeCap != nil {
+		add("stakecap", strconv.FormatFloat(*sc.StakeCap, 'g', -1, 64))
+	}
+	if sc.Events != nil {
+		eventStrs := make([]string, 0, len(*sc.Events))
+		for i := range *sc.Events {
+			eventStrs = append(eventStrs, (*sc.Events)[i].String())
+		}
+		add("events", strings.Join(eventStrs, ","))
+	}
+	if len(sc.NumBlocks) > 0 {
+		numBlocksStrs := make([]string, 0, len(sc.NumBlocks))
+		for _, numBlocks := range sc.NumBlocks {
+			numBlocksStr := strconv.FormatUint(numBlocks, 10)
+			numBlocksStrs = append(numBlocksStrs, numBlocksStr)
+		}
+		add("numblocks", strings.Join(numBlocksStrs, ","))
+	}
+	if len(sc.Seeds) > 0 {
+		seedStrs := make([]string, 0, len(sc.Seeds))
+		for _, seed := range sc.Seeds {
+			seedStrs = append(seedStrs, strconv.FormatInt(seed, 10))
+		}
+		add("seed", strings.Join(seedStrs, ","))
+	}
+	if sc.Runs > 0 {
+		add("runs", strconv.Itoa(sc.Runs))
+	}
+	for _, v := range values {
+		if err := setFlag(v.name, v.value); err != nil {
+			return fmt.Errorf("invalid scenario value for -%s: %v",
+				v.name, err)
+		}
+	}
+	return nil
+}
+
+// groupScenario returns the scenario which reproduces the passed group of
+// simulation runs.  It is embedded in the results of the group.
+func groupScenario(group *runGroup) *scenario {
+	run := group.runs[0]
+	pfRuns := group.runsByPriceFunc()
+	priceFuncs := make([]string, 0, len(pfRuns))
+	for _, runs := range pfRuns {
+		priceFuncs = append(priceFuncs, runs[0].pf.Key)
+	}
+	var monteCarloRuns int
+	if group.isMonteCarlo() {
+		monteCarloRuns = len(pfRuns[0])
+	}
+	var memParams, agentParams, splitParams stakesim.ModelParams
+	if run.cfg.mempool {
+		memParams = run.cfg.mempoolParams
+	}
+	if run.cfg.numAgents > 0 {
+		agentParams = run.cfg.agentParams
+	}
+	if run.cfg.splitTickets {
+		splitParams = run.cfg.splitParams
+	}
+	stakeCap := run.cfg.stakeCap
+	autoRevocationsHeight := run.cfg.autoRevocationsHeight
+	events := run.cfg.events
+	if events == nil {
+		events = []stakesim.Event{}
+	}
+	return &scenario{
+		Net:                   run.cfg.net,
+		NetParams:             run.cfg.netOverrides,
+		PriceFuncs:            priceFuncs,
+		DemandFuncs:           []string{run.df.Key},
+		DemandParams:          run.dfParams,
+		NoiseModel:            run.cfg.noiseModel.Key,
+		NoiseParams:           run.cfg.noiseParams,
+		MissModel:             run.cfg.missModel.Key,
+		MissParams:            run.cfg.missParams,
+		RevokeModel:           run.cfg.revokeModel.Key,
+		RevokeParams:          run.cfg.revokeParams,
+		PurchaseProfile:       run.cfg.purchaseProfile.Key,
+		ProfileParams:         run.cfg.profileParams,
+		Mempool:               run.cfg.mempool,
+		MempoolParams:         memParams,
+		Agents:                run.cfg.numAgents,
+		AgentParams:           agentParams,
+		SplitTickets:          run.cfg.splitTickets,
+		SplitParams:           splitParams,
+		VSPs:                  run.cfg.vsps,
+		StakeCap:              &stakeCap,
+		AutoRevocationsHeight: &autoRevocationsHeight,
+		Events:                &events,
+		NumBlocks:             []uint64{run.numBlocks},
+		Seeds:                 []int64{run.seed},
+		Runs:                  monteCarloRuns,
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/dcrstakesim/stakesim"
+)
+
+// writeScenarioFile writes the passed contents to a scenario file in a new
+// temporary directory and returns the path to it along with a function which
+// removes the directory.
+func writeScenarioFile(t *testing.T, contents []byte) (string, func()) {
+	dir, err := ioutil.TempDir("", "dcrstakesim")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	path := filepath.Join(dir, "scenario.json")
+	if err := ioutil.WriteFile(path, contents, 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("unable to write scenario: %v", err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+// TestScenarioRoundTrip ensures a scenario that is written to JSON, such as the
+// one embedded in the results, loads back to the same scenario.
+func TestScenarioRoundTrip(t *testing.T) {
+	events, err := stakesim.ParseEvents("demand:60%-80%:2," +
+		"freeze:50000-60000:0.25")
+	if err != nil {
+		t.Fatalf("unable to parse events: %v", err)
+	}
+	vsps, err := stakesim.ParseVotingServices("0.3:0.02:0.99")
+	if err != nil {
+		t.Fatalf("unable to parse voting services: %v", err)
+	}
+	stakeCap := 0.4
+	autoRevocationsHeight := int32(4000)
+	want := &scenario{
+		Net:         "testnet",
+		NetParams:   netParamOverrides{"ticketpoolsize": 4096},
+		PriceFuncs:  []string{"current", "7"},
+		DemandFuncs: []string{"b"},
+		DemandParams: stakesim.ModelParams{"loweryield": 0.02,
+			"upperyield": 0.06},
+		StakeCap:              &stakeCap,
+		NoiseModel:            "gaussian",
+		NoiseParams:           stakesim.ModelParams{"stddev": 0.2},
+		MissModel:             "fixed",
+		MissParams:            stakesim.ModelParams{"missrate": 0.01},
+		RevokeModel:           "delayed",
+		RevokeParams:          stakesim.ModelParams{"meandelay": 144},
+		PurchaseProfile:       "uniform",
+		Mempool:               true,
+		MempoolParams:         stakesim.ModelParams{"basefee": 0.001},
+		Agents:                50,
+		AgentParams:           stakesim.ModelParams{"minyield": 0.03},
+		SplitTickets:          true,
+		SplitParams:           stakesim.ModelParams{"contribution": 0.5},
+		VSPs:                  vsps,
+		AutoRevocationsHeight: &autoRevocationsHeight,
+		Events:                &events,
+		NumBlocks:             []uint64{100000},
+		Seeds:                 []int64{1, 2, 3},
+		Runs:                  5,
+	}
+
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("unable to marshal scenario: %v", err)
+	}
+	path, cleanup := writeScenarioFile(t, data)
+	defer cleanup()
+
+	got, err := loadScenario(path)
+	if err != nil {
+		t.Fatalf("unable to load scenario: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("mismatched scenario:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+// TestScenarioDemandParams ensures scenarios written with the demandParams key,
+// such as the example in the README, load the parameters of the demand
+// distribution functions.
+func TestScenarioDemandParams(t *testing.T) {
+	path, cleanup := writeScenarioFile(t, []byte(`{
+  "demandFuncs": ["b"],
+  "demandParams": {"loweryield": 0.02, "upperyield": 0.06}
+}`))
+	defer cleanup()
+
+	sc, err := loadScenario(path)
+	if err != nil {
+		t.Fatalf("unable to load scenario: %v", err)
+	}
+	want := stakesim.ModelParams{"loweryield": 0.02, "upperyield": 0.06}
+	if !reflect.DeepEqual(sc.DemandParams, want) {
+		t.Fatalf("mismatched demand params: got %v, want %v",
+			sc.DemandParams, want)
+	}
+}
@@ -0,0 +1,359 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+
+	"github.com/decred/dcrutil"
+)
+
+// agentStrategy identifies the rule a stakeholder agent uses to decide how much
+// of its balance to spend on tickets.
+type agentStrategy int
+
+// These constants define the supported agent strategies.
+const (
+	// strategyYield purchases tickets whenever the estimated yield is at
+	// or above the yield threshold of the stakeholder, spending less as
+	// the ticket price rises above the recent average price.
+	strategyYield agentStrategy = iota
+
+	// strategyDip only purchases tickets when the ticket price is under
+	// the recent average price, spending more the deeper the dip.
+	strategyDip
+
+	// strategySteady purchases tickets at a steady rate regardless of the
+	// ticket price or yield.
+	strategySteady
+)
+
+// agentStrategyNames maps each agent strategy to its human-readable name.
+var agentStrategyNames = map[agentStrategy]string{
+	strategyYield:  "yield",
+	strategyDip:    "dip",
+	strategySteady: "steady",
+}
+
+// String returns the agent strategy as a human-readable name.
+func (s agentStrategy) String() string {
+	if name, ok := agentStrategyNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown agent strategy (%d)", int(s))
+}
+
+// AgentParams are the tunable parameters of the stakeholder agents.
+var AgentParams = []ModelParam{{
+	Name:         "whalefraction",
+	Description:  "Fraction of the stakeholders that are whales",
+	DefaultValue: 0.01,
+}, {
+	Name:         "whaleshare",
+	Description:  "Fraction of the spendable supply held by whales",
+	DefaultValue: 0.5,
+}, {
+	Name:         "minyield",
+	Description:  "Minimum estimated nominal yield threshold of a stakeholder",
+	DefaultValue: 0.005,
+}, {
+	Name:         "maxyield",
+	Description:  "Maximum estimated nominal yield threshold of a stakeholder",
+	DefaultValue: 0.03,
+}, {
+	Name:         "maxsensitivity",
+	Description:  "Maximum price sensitivity of a stakeholder",
+	DefaultValue: 4,
+}, {
+	Name:         "dipshare",
+	Description:  "Fraction of the stakeholders that only buy price dips",
+	DefaultValue: 0.2,
+}, {
+	Name:         "steadyshare",
+	Description:  "Fraction of the stakeholders that buy at a steady rate",
+	DefaultValue: 0.2,
+}, {
+	Name:         "activity",
+	Description:  "Probability a stakeholder considers purchasing in each block",
+	DefaultValue: 0.01,
+}, {
+	Name:         "allocation",
+	Description:  "Maximum fraction of its balance a stakeholder spends at once",
+	DefaultValue: 0.1,
+}}
+
+// PrintAgentParams prints the tunable parameters of the stakeholder agents
+// along with the available strategies to the passed writer.
+func PrintAgentParams(w io.Writer) {
+	fmt.Fprintln(w, "Stakeholder agent strategies:")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "  yield - Purchase when the estimated yield is at or "+
+		"above the threshold of the stakeholder")
+	fmt.Fprintln(w, "  dip - Only purchase when the ticket price is under "+
+		"the recent average price")
+	fmt.Fprintln(w, "  steady - Purchase at a steady rate regardless of "+
+		"the ticket price")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Stakeholder agent parameters:")
+	PrintModelParams(w, AgentParams)
+}
+
+// ValidateAgentParams returns an error if any of the passed agent parameters
+// are outside of their valid range.
+func ValidateAgentParams(params ModelParams) error {
+	for _, name := range []string{"whalefraction", "whaleshare", "dipshare",
+		"steadyshare", "activity", "allocation"} {
+
+		if params[name] < 0 || params[name] > 1 {
+			return fmt.Errorf("agent parameter %q must be in the "+
+				"range [0, 1]", name)
+		}
+	}
+	switch {
+	case params["minyield"] < 0 || params["minyield"] > params["maxyield"]:
+		return fmt.Errorf("agent parameter \"minyield\" must be in "+
+			"the range [0, %v]", params["maxyield"])
+	case params["maxsensitivity"] < 0:
+		return fmt.Errorf("agent parameter \"maxsensitivity\" must " +
+			"not be negative")
+	case params["dipshare"]+params["steadyshare"] > 1:
+		return fmt.Errorf("agent parameters \"dipshare\" and " +
+			"\"steadyshare\" must not sum to more than 1")
+	}
+	return nil
+}
+
+// stakeholder houses the attributes of a single stakeholder agent along with
+// the number of tickets it has purchased and how often it was priced out.
+type stakeholder struct {
+	// share is the fraction of the spendable supply held by the
+	// stakeholder.
+	share float64
+
+	// whale is whether or not the stakeholder is one of the few that hold
+	// a large portion of the spendable supply.
+	whale bool
+
+	// yieldThreshold is the minimum estimated nominal yield the
+	// stakeholder accepts when using the yield strategy.
+	yieldThreshold float64
+
+	// priceSensitivity controls how strongly the stakeholder reacts to the
+	// ticket price differing from the recent average price.
+	priceSensitivity float64
+
+	strategy      agentStrategy
+	ticketsBought int
+
+	// splitTickets is the number of split tickets the stakeholder shared
+	// and pricedOut is the number of times it decided to purchase but could
+	// neither afford a full ticket nor share a split ticket.
+	splitTickets int
+	pricedOut    int
+}
+
+// isPricedOut returns whether or not the stakeholder was priced out at least
+// once without ever purchasing or sharing a ticket.
+func (sh *stakeholder) isPricedOut() bool {
+	return sh.pricedOut > 0 && sh.ticketsBought == 0 && sh.splitTickets == 0
+}
+
+// spendFraction returns the fraction of its maximum allocation the stakeholder
+// spends given the estimated yield and the ratio of the ticket price to the
+// recent average price.
+func (sh *stakeholder) spendFraction(yield, priceRatio float64) float64 {
+	switch sh.strategy {
+	case strategyYield:
+		if yield < sh.yieldThreshold {
+			return 0
+		}
+		return math.Exp(-sh.priceSensitivity * math.Max(0, priceRatio-1))
+
+	case strategyDip:
+		if priceRatio >= 1 {
+			return 0
+		}
+		return math.Min(1, sh.priceSensitivity*(1-priceRatio))
+	}
+
+	return 1
+}
+
+// agentPopulation houses the stakeholder agents which make the ticket purchase
+// decisions when purchasing is simulated with agents instead of the aggregate
+// demand.
+type agentPopulation struct {
+	params       ModelParams
+	stakeholders []*stakeholder
+
+	// splitParams are the parameters of the split tickets stakeholders
+	// that cannot afford a full ticket use to purchase tickets jointly.  It
+	// is nil when split tickets are disabled.
+	splitParams     ModelParams
+	session         splitSession
+	numSplitTickets int
+
+	// vwap is the volume-weighted average ticket price of the previous
+	// windows as of vwapHeight.  It only changes once per window, so it is
+	// cached to avoid recalculating it every block.
+	vwap       int64
+	vwapHeight int32
+}
+
+// newAgentPopulation returns a population of the provided number of stakeholder
+// agents with attributes drawn from the passed random source according to the
+// given parameters, which must contain a value for every agent parameter.
+//
+// Whales split their share of the spendable supply amongst themselves and the
+// remaining stakeholders split the rest, each in proportion to exponentially
+// distributed weights.
+func newAgentPopulation(rng *rand.Rand, count int, params ModelParams) *agentPopulation {
+	numWhales := int(float64(count)*params["whalefraction"] + 0.5)
+	whaleShare := params["whaleshare"]
+	switch {
+	case numWhales == 0:
+		whaleShare = 0
+	case numWhales == count:
+		whaleShare = 1
+	}
+
+	stakeholders := make([]*stakeholder, 0, count)
+	var whaleWeights, otherWeights float64
+	minYield, maxYield := params["minyield"], params["maxyield"]
+	dipShare, steadyShare := params["dipshare"], params["steadyshare"]
+	for i := 0; i < count; i++ {
+		sh := &stakeholder{
+			share:            rng.ExpFloat64(),
+			whale:            i < numWhales,
+			yieldThreshold:   minYield + rng.Float64()*(maxYield-minYield),
+			priceSensitivity: rng.Float64() * params["maxsensitivity"],
+		}
+		switch r := rng.Float64(); {
+		case r < dipShare:
+			sh.strategy = strategyDip
+		case r < dipShare+steadyShare:
+			sh.strategy = strategySteady
+		default:
+			sh.strategy = strategyYield
+		}
+		if sh.whale {
+			whaleWeights += sh.share
+		} else {
+			otherWeights += sh.share
+		}
+		stakeholders = append(stakeholders, sh)
+	}
+	for _, sh := range stakeholders {
+		if sh.whale {
+			sh.share *= whaleShare / whaleWeights
+		} else {
+			sh.share *= (1 - whaleShare) / otherWeights
+		}
+	}
+
+	return &agentPopulation{
+		params:       params,
+		stakeholders: stakeholders,
+		vwapHeight:   -1,
+	}
+}
+
+// clone returns a deep copy of the stakeholder agents, including the current
+// split ticket session, that is not affected by any changes to the original.
+func (ap *agentPopulation) clone() *agentPopulation {
+	clone := *ap
+	clone.stakeholders = make([]*stakeholder, 0, len(ap.stakeholders))
+	clones := make(map[*stakeholder]*stakeholder, len(ap.stakeholders))
+	for _, sh := range ap.stakeholders {
+		shClone := *sh
+		clone.stakeholders = append(clone.stakeholders, &shClone)
+		clones[sh] = &shClone
+	}
+	clone.session.participants = make([]*stakeholder, 0,
+		len(ap.session.participants))
+	for _, participant := range ap.session.participants {
+		clone.session.participants = append(clone.session.participants,
+			clones[participant])
+	}
+	return &clone
+}
+
+// agentPurchases returns the number of tickets the stakeholder agents purchase
+// in the block at the provided height given the ticket price and the spendable
+// supply.  The total is limited to the maximum number of new tickets allowed
+// per block.
+//
+// Each stakeholder considers purchasing with a probability set by its activity,
+// which is scaled by any active demand events, and spends up to its allocation
+// of its balance according to its strategy.  Stakeholders whose allocation
+// does not cover a full ticket, but whose balance does, purchase a single
+// ticket with a probability in proportion to the fraction of it they would
+// spend.  Stakeholders whose balance does not cover a full ticket are priced
+// out unless split tickets are enabled, in which case they contribute to a
+// split ticket that is purchased once the contributions cover the ticket
+// price.  The participants of a split ticket whose contributions still do not
+// cover the ticket price by the end of the block are priced out.  The
+// stakeholders are visited starting from a random position so no stakeholder
+// is favored when the block fills up.
+func (s *Simulator) agentPurchases(nextHeight int32, ticketPrice int64, spendableSupply dcrutil.Amount) uint8 {
+	agents := s.agents
+	if s.tip == nil || len(agents.stakeholders) == 0 {
+		return 0
+	}
+
+	// Update the cached volume-weighted average price once per window.
+	windowSize := int32(s.params.StakeDiffWindowSize)
+	windowStart := nextHeight - nextHeight%windowSize
+	if agents.vwapHeight != windowStart {
+		agents.vwap = s.calcPrevVWAP(s.tip)
+		agents.vwapHeight = windowStart
+	}
+
+	yield := s.estimatedYield(nextHeight, ticketPrice)
+	priceRatio := float64(ticketPrice) / float64(agents.vwap)
+	activity := agents.params["activity"] * s.demandMultiplier(nextHeight)
+	allocation := agents.params["allocation"]
+
+	remaining := int64(s.params.MaxFreshStakePerBlock)
+	numStakeholders := len(agents.stakeholders)
+	offset := s.rng.Intn(numStakeholders)
+	for i := 0; i < numStakeholders && remaining > 0; i++ {
+		sh := agents.stakeholders[(offset+i)%numStakeholders]
+		if s.rng.Float64() >= activity {
+			continue
+		}
+
+		balance := sh.share * float64(spendableSupply)
+		fraction := sh.spendFraction(yield, priceRatio)
+		spend := fraction * allocation * balance
+		if balance < float64(ticketPrice) {
+			if spend > 0 && s.splitTicketPurchase(sh, fraction*balance,
+				ticketPrice) {
+
+				remaining--
+			}
+			continue
+		}
+		want := int64(spend / float64(ticketPrice))
+		if want == 0 && balance >= float64(ticketPrice) &&
+			s.rng.Float64() < spend/float64(ticketPrice) {
+
+			want = 1
+		}
+		if want > remaining {
+			want = remaining
+		}
+		sh.ticketsBought += int(want)
+		remaining -= want
+	}
+	if agents.splitParams != nil {
+		agents.session.abandon()
+	}
+
+	return uint8(int64(s.params.MaxFreshStakePerBlock) - remaining)
+}
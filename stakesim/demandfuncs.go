@@ -0,0 +1,321 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/decred/dcrutil"
+)
+
+// DemandFunc describes a function which returns the simulated demand (as a
+// percentage of the number of tickets to purchase within a given stake
+// difficulty interval) along with details about its behavior so the simulation
+// results are self describing.
+type DemandFunc struct {
+	Model
+
+	// Calc returns the demand for the provided next height and the ticket
+	// price produced by the next ticket price func.  The passed parameters
+	// contain a value for every parameter the function defines and the
+	// returned result must be in the range [0, 1].
+	Calc func(s *Simulator, params ModelParams, nextHeight int32, ticketPrice int64) float64
+}
+
+// demandFuncs houses all registered demand distribution functions in
+// the order they were registered.
+var demandFuncs = newModelRegistry("demand distribution function")
+
+// RegisterDemandFunc makes the provided demand distribution function available
+// to the simulator under its key.  Much like RegisterPriceFunc, it is intended
+// to be called from the init function of the file that defines the function.
+//
+// This function will panic if the key is empty or reserved, the calculation
+// function is nil, or a demand function with the same key has already been
+// registered since those are programming errors.
+func RegisterDemandFunc(df *DemandFunc) {
+	demandFuncs.register(df.Key, df, df.Calc != nil)
+}
+
+// LookupDemandFunc returns the registered demand distribution function for the
+// provided key or nil when there is no such function.
+func LookupDemandFunc(key string) *DemandFunc {
+	df, _ := demandFuncs.lookup(key).(*DemandFunc)
+	return df
+}
+
+// DemandFuncKeys returns the keys of all registered demand distribution
+// functions in the order they were registered.
+func DemandFuncKeys() []string {
+	return demandFuncs.registeredKeys()
+}
+
+// PrintDemandFuncs prints all of the registered demand distribution functions
+// along with their descriptions and tunable parameters to the passed writer.
+func PrintDemandFuncs(w io.Writer) {
+	demandFuncs.print(w, "Available demand distribution functions:")
+}
+
+// yieldParams are the tunable parameters for demand distribution functions
+// that are based on the estimated nominal yield.
+var yieldParams = []ModelParam{{
+	Name:         "loweryield",
+	Description:  "Minimum acceptable estimated nominal yield",
+	DefaultValue: 0.02,
+}, {
+	Name:         "upperyield",
+	Description:  "Estimated nominal yield at or above which there is 100% demand",
+	DefaultValue: 0.05,
+}}
+
+// validateYieldParams returns an error when the yield parameters of the demand
+// distribution functions that are based on the estimated nominal yield do not
+// describe a valid range of yields since the demand is calculated from their
+// ratio.
+func validateYieldParams(params ModelParams) error {
+	lowerYield, upperYield := params["loweryield"], params["upperyield"]
+	if !(lowerYield > 0) {
+		return fmt.Errorf("loweryield %v must be positive", lowerYield)
+	}
+	if !(upperYield > lowerYield) {
+		return fmt.Errorf("upperyield %v must be greater than "+
+			"loweryield %v", upperYield, lowerYield)
+	}
+	return nil
+}
+
+func init() {
+	RegisterDemandFunc(&DemandFunc{
+		Model: Model{
+			Key:         "a",
+			Description: "Purchase based on estimated nominal yield and volume-weighted average price",
+			Params:      yieldParams,
+			Validate:    validateYieldParams,
+		},
+		Calc: (*Simulator).demandFuncA,
+	})
+	RegisterDemandFunc(&DemandFunc{
+		Model: Model{
+			Key:         "b",
+			Description: "Purchase based on estimated nominal yield",
+			Params:      yieldParams,
+			Validate:    validateYieldParams,
+		},
+		Calc: (*Simulator).demandFuncB,
+	})
+	RegisterDemandFunc(&DemandFunc{
+		Model: Model{
+			Key:         "c",
+			Description: "Alternate between purchasing based solely on estimated nominal yield and including volume-weighted average price each interval",
+			Params:      yieldParams,
+			Validate:    validateYieldParams,
+		},
+		Calc: (*Simulator).demandFuncC,
+	})
+	RegisterDemandFunc(&DemandFunc{
+		Model: Model{
+			Key:         "d",
+			Description: "Alternate between full demand and no demand",
+			Params: []ModelParam{{
+				Name:         "intervals",
+				Description:  "Number of intervals to remain at full or no demand before alternating",
+				DefaultValue: 4,
+			}},
+			Validate: func(params ModelParams) error {
+				return validateAtLeast(params, 1, "intervals")
+			},
+		},
+		Calc: (*Simulator).demandFuncD,
+	})
+	RegisterDemandFunc(&DemandFunc{
+		Model: Model{
+			Key:         "full",
+			Description: "Purchase with 100% demand",
+		},
+		Calc: func(*Simulator, ModelParams, int32, int64) float64 {
+			return 1.0
+		},
+	})
+}
+
+// estimatedYield returns the estimated expected nominal yield of purchasing a
+// ticket at the provided price in the block at the provided height assuming it
+// votes after the typical 28 days.
+//
+// The payout is limited to the ticket expiry since a ticket can not vote after
+// it expires.  This keeps the estimate sensible on networks with very short
+// block times, such as simnet, where 28 days would otherwise be so many blocks
+// that the subsidy has been reduced to nothing.
+func (s *Simulator) estimatedYield(nextHeight int32, ticketPrice int64) float64 {
+	expectedPayoutHeight := int32((time.Hour * 24) * 28 / s.params.TargetTimePerBlock)
+	if ticketExpiry := int32(s.params.TicketExpiry); expectedPayoutHeight > ticketExpiry {
+		expectedPayoutHeight = ticketExpiry
+	}
+	ticketsPerBlock := s.params.TicketsPerBlock
+	posSubsidy := s.CalcPoSSubsidy(nextHeight + expectedPayoutHeight - 1)
+	perVoteSubsidy := posSubsidy / dcrutil.Amount(ticketsPerBlock)
+	return float64(perVoteSubsidy) / float64(ticketPrice)
+}
+
+// calcYieldDemand returns a simulated demand (as a percentage of the number of
+// tickets to purchase within a given stake difficulty interval) based upon the
+// estimated yield purchasing a ticket would produce.
+//
+// The passed parameters specify the base minimum acceptable estimated nominal
+// yield and the upper yield after which there is 100% demand.  They are
+// typically 2% and 5%, respectively.
+func (s *Simulator) calcYieldDemand(params ModelParams, nextHeight int32, ticketPrice int64) float64 {
+	const minYield = 0.00083
+	baseLowerYield := params["loweryield"]
+	baseUpperYield := params["upperyield"]
+	yieldSpread := baseLowerYield / baseUpperYield
+	minUpperYield := minYield / yieldSpread
+
+	// Scale the yield down over time reflect rational behavior where
+	// stakeholders will accept lower yields as higher ones are no longer
+	// available due to a reducing subsidy.
+	reductions := nextHeight / int32(s.params.SubsidyReductionInterval*5)
+	lowerYield := baseLowerYield - (0.001 * float64(reductions))
+	lowerYield = math.Max(lowerYield, minYield)
+	upperYield := math.Max(lowerYield/yieldSpread, minUpperYield)
+
+	// 100% demand when the yield is high enough.
+	yield := s.estimatedYield(nextHeight, ticketPrice)
+	if yield > upperYield {
+		return 1.0
+	}
+
+	// No demand when the yield is under minimum acceptable yield.
+	if yield < lowerYield {
+		return 0.0
+	}
+
+	// The yield is between the acceptable range, so create a linear demand
+	// accordingly.
+	return (yield - lowerYield) / (upperYield - lowerYield)
+}
+
+// calcVWAPDemand returns a simulated demand (as a percentage of the number of
+// tickets to purchase within a given stake difficulty interval) based upon the
+// volume-weighted average ticket purchase of the previous ticket price windows.
+func (s *Simulator) calcVWAPDemand(ticketPrice int64) float64 {
+	// 100% demand when the ticket price is under 80% of the VWAP.
+	ticketVWAP := s.calcPrevVWAP(s.tip)
+	eightyPercentVWAP := (ticketVWAP * 8) / 10
+	if ticketPrice < eightyPercentVWAP {
+		return 1.0
+	}
+
+	// No demand when the ticket price is over 120% of the VWAP.
+	if ticketPrice > (ticketVWAP*12)/10 {
+		return 0.0
+	}
+
+	// The ticket price is in between 80% and 120% of the VWAP, so create
+	// a linear demand accordingly.
+	fortyPercentVWAP := (ticketVWAP * 4) / 10
+	return 1 - float64(ticketPrice-eightyPercentVWAP)/float64(fortyPercentVWAP)
+}
+
+// calcVWAP calculates and return the volume-weighted average ticket purchase
+// price for up to 'StakeDiffWindows' worth of the previous ticket price
+// windows.
+func (s *Simulator) calcPrevVWAP(prevNode *BlockNode) int64 {
+	windowSize := int32(s.params.StakeDiffWindowSize)
+	stakeDiffWindows := int32(s.params.StakeDiffWindows)
+
+	// Calculate the height the block just before the most recent ticket
+	// price change.
+	wantHeight := prevNode.height - (prevNode.height+1)%windowSize
+	prevNode = s.ancestorNode(prevNode, wantHeight, nil)
+
+	// Loop through previous required number of previous blocks and tally up
+	// all of the weighted ticket purchase prices as well as the total
+	// number of purchased tickets.
+	numTickets, weightedVal := new(big.Int), new(big.Int)
+	weightedSum, totalTickets := new(big.Int), new(big.Int)
+	blocksToIterate := stakeDiffWindows * windowSize
+	for i := int32(0); i < blocksToIterate && prevNode != nil; i++ {
+		// weightedSum += numTickets*ticketPrice
+		// totalTickets += numTickets
+		numTickets.SetInt64(int64(len(prevNode.ticketsAdded)))
+		weightedVal.SetInt64(prevNode.ticketPrice)
+		weightedVal.Mul(weightedVal, numTickets)
+		weightedSum.Add(weightedSum, weightedVal)
+		totalTickets.Add(totalTickets, numTickets)
+		prevNode = prevNode.parent
+	}
+
+	// Return minimum ticket price if there were not any ticket purchases at
+	// all in the entire period being examined.
+	if totalTickets.Sign() == 0 {
+		return s.params.MinimumStakeDiff
+	}
+
+	return new(big.Int).Div(weightedSum, totalTickets).Int64()
+}
+
+// demandFuncA returns a simulated demand (as a percentage of the number of
+// tickets to purchase within a given stake difficulty interval) based upon
+// a combination of the estimated yield purchasing a ticket would price and the
+// volume-weighted average ticket purchase price.
+func (s *Simulator) demandFuncA(params ModelParams, nextHeight int32, ticketPrice int64) float64 {
+	// Calculate the demand based on yield.
+	yieldDemand := s.calcYieldDemand(params, nextHeight, ticketPrice)
+
+	// Calculate the demand based on the volume-weighted average ticket
+	// purchase price.
+	vwapDemand := s.calcVWAPDemand(ticketPrice)
+
+	// The demand is the combination of the two unless there is full demand
+	// based on yield and no demand based on the VWAP, in which case there
+	// is 100% demand.
+	demand := yieldDemand * vwapDemand
+	if yieldDemand == 1.0 && vwapDemand == 0.0 {
+		demand = 1.0
+	}
+
+	return demand
+}
+
+// demandFuncB returns a simulated demand (as a percentage of the number of
+// tickets to purchase within a given stake difficulty interval) based upon the
+// estimated yield purchasing a ticket would produce.
+func (s *Simulator) demandFuncB(params ModelParams, nextHeight int32, ticketPrice int64) float64 {
+	demand := s.calcYieldDemand(params, nextHeight, ticketPrice)
+
+	return demand
+}
+
+// demandFuncC returns a simulated demand (as a percentage of the number of
+// tickets to purchase within a given stake difficulty interval) based upon
+// alternating between demandFuncA and demandFuncB each interval.
+func (s *Simulator) demandFuncC(params ModelParams, nextHeight int32, ticketPrice int64) float64 {
+	interval := int64(nextHeight) / s.params.StakeDiffWindowSize
+	if interval%2 == 0 {
+		return s.demandFuncA(params, nextHeight, ticketPrice)
+	}
+	return s.demandFuncB(params, nextHeight, ticketPrice)
+}
+
+// demandFuncD returns a simulated demand (as a percentage of the number of
+// tickets to purchase within a given stake difficulty interval) based upon
+// alternating between full demand and no demand after the number of intervals
+// specified by the passed parameters.
+func (s *Simulator) demandFuncD(params ModelParams, nextHeight int32, ticketPrice int64) float64 {
+	intervals := int64(params["intervals"])
+	if intervals < 1 {
+		intervals = 1
+	}
+	interval := int64(nextHeight) / (s.params.StakeDiffWindowSize * intervals)
+	if interval%2 == 0 {
+		return 0.0
+	}
+	return 1.0
+}
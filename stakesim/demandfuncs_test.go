@@ -0,0 +1,38 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"testing"
+
+	"github.com/decred/dcrd/chaincfg"
+)
+
+// TestSimulateSimnet ensures the default demand distribution function produces
+// enough demand on simnet, whose very short block time would otherwise put the
+// expected payout of a ticket long after the subsidy has been reduced to
+// nothing, to simulate past stake validation height.
+func TestSimulateSimnet(t *testing.T) {
+	params := &chaincfg.SimNetParams
+	sim, err := New(&Config{Params: params})
+	if err != nil {
+		t.Fatalf("unable to create simulator: %v", err)
+	}
+
+	svh := int32(params.StakeValidationHeight)
+	if yield := sim.estimatedYield(svh, params.MinimumStakeDiff); yield <= 0 {
+		t.Fatalf("estimated yield at stake validation height: got %v, "+
+			"want > 0", yield)
+	}
+
+	numBlocks := uint64(svh) * 3
+	if err := sim.Simulate(numBlocks); err != nil {
+		t.Fatalf("unable to simulate %d simnet blocks: %v", numBlocks,
+			err)
+	}
+	if sim.Tip().PoolSize() == 0 {
+		t.Fatal("live ticket pool is empty")
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import "fmt"
+
+// ErrorCode identifies a kind of simulation error.
+type ErrorCode int
+
+// These constants are used to identify a specific SimError.
+const (
+	// ErrTooManyNewTickets indicates the simulation data attempted to
+	// purchase more new tickets in a block than the maximum allowed.
+	ErrTooManyNewTickets ErrorCode = iota
+
+	// ErrTooManyVotes indicates the simulation data attempted to include
+	// more votes in a block than the number of tickets per block.
+	ErrTooManyVotes
+
+	// ErrTooFewVotes indicates the simulation data attempted to include
+	// fewer votes in a block than the majority required after stake
+	// validation height.
+	ErrTooFewVotes
+
+	// ErrTooManyRevocations indicates the simulation data attempted to
+	// revoke more tickets than there are unrevoked tickets.
+	ErrTooManyRevocations
+
+	// ErrEarlyPurchase indicates the simulation data attempted to purchase
+	// tickets before any coins are spendable.
+	ErrEarlyPurchase
+
+	// ErrEarlyVote indicates the simulation data attempted to include votes
+	// before stake validation height.
+	ErrEarlyVote
+
+	// ErrEarlyRevocation indicates the simulation data attempted to revoke
+	// tickets before stake validation height.
+	ErrEarlyRevocation
+
+	// ErrMissedVotesMismatch indicates the winning tickets the simulation
+	// data identified as missing their votes do not agree with the number
+	// of votes.
+	ErrMissedVotesMismatch
+
+	// ErrLottery indicates the winning tickets of a block could not be
+	// selected from the live ticket pool.
+	ErrLottery
+
+	// ErrPriceTooLow indicates a ticket price function returned a price
+	// under the minimum allowed stake difficulty.
+	ErrPriceTooLow
+
+	// ErrDemandOutOfRange indicates a demand distribution function
+	// returned a demand outside of the range [0, 1].
+	ErrDemandOutOfRange
+
+	// ErrInvertedEvent indicates an event starts after it ends once its
+	// heights are resolved for the number of simulated blocks.
+	ErrInvertedEvent
+)
+
+// errorCodeStrings is a map of error codes back to their constant names for
+// pretty printing.
+var errorCodeStrings = map[ErrorCode]string{
+	ErrTooManyNewTickets:   "ErrTooManyNewTickets",
+	ErrTooManyVotes:        "ErrTooManyVotes",
+	ErrTooFewVotes:         "ErrTooFewVotes",
+	ErrTooManyRevocations:  "ErrTooManyRevocations",
+	ErrEarlyPurchase:       "ErrEarlyPurchase",
+	ErrEarlyVote:           "ErrEarlyVote",
+	ErrEarlyRevocation:     "ErrEarlyRevocation",
+	ErrMissedVotesMismatch: "ErrMissedVotesMismatch",
+	ErrLottery:             "ErrLottery",
+	ErrPriceTooLow:         "ErrPriceTooLow",
+	ErrDemandOutOfRange:    "ErrDemandOutOfRange",
+	ErrInvertedEvent:       "ErrInvertedEvent",
+}
+
+// String returns the ErrorCode as a human-readable name.
+func (e ErrorCode) String() string {
+	if s := errorCodeStrings[e]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown errorCode (%d)", int(e))
+}
+
+// SimError identifies invalid simulation data or a misbehaving ticket price or
+// demand distribution function along with the height of the block being
+// simulated and the offending value.  The caller can use type assertions to
+// determine if an error is a SimError and access the code to determine the
+// specific reason.
+type SimError struct {
+	ErrorCode   ErrorCode
+	Height      int32
+	Value       float64
+	Limit       float64
+	Description string
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e SimError) Error() string {
+	return e.Description
+}
+
+// newSimError creates a SimError given a set of arguments.  The value is the
+// offending value and the limit is the value it violated.
+func newSimError(code ErrorCode, height int32, value, limit float64, description string) SimError {
+	return SimError{ErrorCode: code, Height: height, Value: value,
+		Limit: limit, Description: description}
+}
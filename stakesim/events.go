@@ -0,0 +1,350 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/decred/dcrutil"
+)
+
+// eventKind identifies the effect a simulated event has on ticket purchasing.
+type eventKind string
+
+const (
+	// eventDemand multiplies the demand produced by the demand distribution
+	// function by the value of the event.  For example, a value of 2
+	// doubles the demand while a value of 0.5 halves it.
+	eventDemand eventKind = "demand"
+
+	// eventStakeCap changes the maximum fraction of the total supply that
+	// may be staked to the value of the event.
+	eventStakeCap eventKind = "stakecap"
+
+	// eventFreeze simulates stakeholders losing interest in staking by
+	// preventing any tickets from being purchased until the staked coins
+	// have dropped by the fraction of the value of the event from the
+	// amount staked when the event started.  Coins can not be pulled out
+	// of existing tickets, so the staked coins only drop as the tickets
+	// vote or are revoked.
+	eventFreeze eventKind = "freeze"
+)
+
+// eventKindDescriptions provides human-readable descriptions of the supported
+// event kinds.  It is also used to validate the kind of parsed events.
+var eventKindDescriptions = map[eventKind]string{
+	eventDemand:   "Demand multiplied by",
+	eventStakeCap: "Stake cap changed to",
+	eventFreeze:   "Purchases frozen until staked coins drop by",
+}
+
+// DefaultEvents reproduces the original hard-coded surge which doubles the
+// demand and raises the stake cap from 40% to 60% of the total supply between
+// 60% and 80% of the simulated blocks.
+const DefaultEvents = "demand:60%-80%:2,stakecap:60%-80%:0.6"
+
+// eventHeight is a height at which an event starts or ends.  It is either an
+// absolute block height or a percentage of the number of simulated blocks.
+type eventHeight struct {
+	value   float64
+	percent bool
+}
+
+// parseEventHeight parses a height such as "1000" or "60%".
+func parseEventHeight(str string) (eventHeight, error) {
+	var h eventHeight
+	if strings.HasSuffix(str, "%") {
+		h.percent = true
+		str = strings.TrimSuffix(str, "%")
+	}
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil || value < 0 || (h.percent && value > 100) {
+		return eventHeight{}, fmt.Errorf("invalid event height %q", str)
+	}
+	if !h.percent && value != math.Trunc(value) {
+		return eventHeight{}, fmt.Errorf("invalid event height %q", str)
+	}
+	h.value = value
+	return h, nil
+}
+
+// String returns the height in the same form accepted by parseEventHeight.
+func (h eventHeight) String() string {
+	str := strconv.FormatFloat(h.value, 'f', -1, 64)
+	if h.percent {
+		str += "%"
+	}
+	return str
+}
+
+// resolve returns the absolute block height for the provided number of
+// simulated blocks.
+func (h eventHeight) resolve(numBlocks uint64) int32 {
+	if h.percent {
+		return int32(float64(numBlocks) * h.value / 100)
+	}
+	return int32(h.value)
+}
+
+// MarshalJSON encodes absolute heights as JSON numbers and relative heights as
+// JSON strings such as "60%".
+func (h eventHeight) MarshalJSON() ([]byte, error) {
+	if h.percent {
+		return json.Marshal(h.String())
+	}
+	return json.Marshal(int64(h.value))
+}
+
+// UnmarshalJSON decodes a height from either a JSON number or a JSON string in
+// the form accepted by parseEventHeight.
+func (h *eventHeight) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		str = string(data)
+	}
+	height, err := parseEventHeight(str)
+	if err != nil {
+		return err
+	}
+	*h = height
+	return nil
+}
+
+// Event describes an event that changes ticket purchasing behavior between
+// a start and end height, inclusive.
+type Event struct {
+	kind  eventKind
+	start eventHeight
+	end   eventHeight
+	value float64
+}
+
+// String returns the event in the same form accepted by ParseEvents.
+func (e *Event) String() string {
+	return fmt.Sprintf("%s:%s-%s:%s", e.kind, e.start, e.end,
+		strconv.FormatFloat(e.value, 'f', -1, 64))
+}
+
+// simEventJSON is the JSON representation of an Event used in scenarios.
+type simEventJSON struct {
+	Kind  eventKind   `json:"kind"`
+	Start eventHeight `json:"start"`
+	End   eventHeight `json:"end"`
+	Value float64     `json:"value"`
+}
+
+// MarshalJSON encodes the event as a JSON object.
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal(simEventJSON{e.kind, e.start, e.end, e.value})
+}
+
+// UnmarshalJSON decodes and validates an event from a JSON object.
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var ej simEventJSON
+	if err := json.Unmarshal(data, &ej); err != nil {
+		return err
+	}
+	event := Event{kind: ej.Kind, start: ej.Start, end: ej.End,
+		value: ej.Value}
+	if err := event.validate(); err != nil {
+		return err
+	}
+	*e = event
+	return nil
+}
+
+// Description returns a human-readable description of the effect of the event.
+func (e *Event) Description() string {
+	value := strconv.FormatFloat(e.value, 'f', -1, 64)
+	switch e.kind {
+	case eventStakeCap, eventFreeze:
+		value = strconv.FormatFloat(e.value*100, 'f', -1, 64) + "%"
+	}
+	return eventKindDescriptions[e.kind] + " " + value
+}
+
+// validate returns an error when the event is of an unknown kind, its value is
+// not sensible for its kind, or it starts after it ends.
+func (e *Event) validate() error {
+	switch e.kind {
+	case eventDemand:
+		if e.value < 0 {
+			return fmt.Errorf("demand event %q must have a "+
+				"non-negative multiplier", e)
+		}
+	case eventStakeCap, eventFreeze:
+		if e.value <= 0 || e.value > 1 {
+			return fmt.Errorf("%s event %q must have a value in the "+
+				"range (0, 1]", e.kind, e)
+		}
+	default:
+		return fmt.Errorf("unknown event kind %q", e.kind)
+	}
+
+	// The range can only be checked when both heights are absolute or
+	// both are relative since the number of simulated blocks is unknown.
+	// Other events are checked once they are scheduled.
+	if e.start.percent == e.end.percent && e.start.value > e.end.value {
+		return fmt.Errorf("event %q starts after it ends", e)
+	}
+	return nil
+}
+
+// ParseEvents parses a comma-separated list of events of the form
+// kind:start-end:value, such as "demand:60%-80%:2,freeze:50000-60000:0.25".
+// Heights that end with a percent sign are relative to the number of simulated
+// blocks.
+func ParseEvents(str string) ([]Event, error) {
+	if strings.TrimSpace(str) == "" {
+		return nil, nil
+	}
+
+	var events []Event
+	for _, eventStr := range SplitList(str) {
+		fields := strings.Split(eventStr, ":")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("event %q is not of the form "+
+				"kind:start-end:value", eventStr)
+		}
+		heights := strings.Split(fields[1], "-")
+		if len(heights) != 2 {
+			return nil, fmt.Errorf("event %q does not have a range "+
+				"of the form start-end", eventStr)
+		}
+		start, err := parseEventHeight(heights[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseEventHeight(heights[1])
+		if err != nil {
+			return nil, err
+		}
+		value, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for event %q: %v",
+				eventStr, err)
+		}
+		event := Event{
+			kind:  eventKind(fields[0]),
+			start: start,
+			end:   end,
+			value: value,
+		}
+		if err := event.validate(); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// ScheduledEvent is an event with its start and end heights resolved for a
+// specific simulation.  It is not modified once it is scheduled, so it may be
+// shared with snapshots.
+type ScheduledEvent struct {
+	*Event
+	StartHeight int32
+	EndHeight   int32
+}
+
+// isActive returns whether or not the event applies to the provided height.
+func (e *ScheduledEvent) isActive(height int32) bool {
+	return height >= e.StartHeight && height <= e.EndHeight
+}
+
+// scheduleEvents resolves the heights of the configured events for the
+// provided number of simulated blocks.  The events are only resolved once, so
+// later calls, such as when a simulation is continued in multiple chunks or
+// from a restored snapshot, keep the heights of the first call.
+//
+// An ErrInvertedEvent error is returned when an event starts after it ends
+// once its heights are resolved, such as an event that starts at an absolute
+// height after a relative end height, since it would never apply.
+func (s *Simulator) scheduleEvents(numBlocks uint64) error {
+	if s.scheduledEvents != nil {
+		return nil
+	}
+
+	scheduledEvents := make([]*ScheduledEvent, 0, len(s.events))
+	for i := range s.events {
+		event := &s.events[i]
+		scheduled := &ScheduledEvent{
+			Event:       event,
+			StartHeight: event.start.resolve(numBlocks),
+			EndHeight:   event.end.resolve(numBlocks),
+		}
+		if scheduled.StartHeight > scheduled.EndHeight {
+			str := fmt.Sprintf("event %q starts at height %d after "+
+				"it ends at height %d when simulating %d "+
+				"blocks", event, scheduled.StartHeight,
+				scheduled.EndHeight, numBlocks)
+			return newSimError(ErrInvertedEvent,
+				scheduled.StartHeight,
+				float64(scheduled.StartHeight),
+				float64(scheduled.EndHeight), str)
+		}
+		scheduledEvents = append(scheduledEvents, scheduled)
+	}
+	s.scheduledEvents = scheduledEvents
+	s.freezeTargets = make(map[*ScheduledEvent]dcrutil.Amount)
+	return nil
+}
+
+// demandMultiplier returns the product of the values of all demand events that
+// are active at the provided height.
+func (s *Simulator) demandMultiplier(height int32) float64 {
+	multiplier := 1.0
+	for _, event := range s.scheduledEvents {
+		if event.kind == eventDemand && event.isActive(height) {
+			multiplier *= event.value
+		}
+	}
+	return multiplier
+}
+
+// applyDemandEvents returns the provided demand adjusted by all demand events
+// that are active at the provided height.  The result is limited to a maximum
+// of 1.
+func (s *Simulator) applyDemandEvents(height int32, demand float64) float64 {
+	return math.Min(1, demand*s.demandMultiplier(height))
+}
+
+// activeStakeCap returns the maximum fraction of the total supply that may be
+// staked at the provided height.  The most recently listed stake cap event
+// that is active takes precedence over the default stake cap.
+func (s *Simulator) activeStakeCap(height int32) float64 {
+	stakeCap := s.stakeCap
+	for _, event := range s.scheduledEvents {
+		if event.kind == eventStakeCap && event.isActive(height) {
+			stakeCap = event.value
+		}
+	}
+	return stakeCap
+}
+
+// isFrozen returns whether or not any freeze event that is active at the
+// provided height is still preventing purchases.
+func (s *Simulator) isFrozen(height int32, stakedCoins dcrutil.Amount) bool {
+	var frozen bool
+	for _, event := range s.scheduledEvents {
+		if event.kind != eventFreeze || !event.isActive(height) {
+			continue
+		}
+		target, ok := s.freezeTargets[event]
+		if !ok {
+			target = dcrutil.Amount(float64(stakedCoins) *
+				(1 - event.value))
+			s.freezeTargets[event] = target
+		}
+		if stakedCoins > target {
+			frozen = true
+		}
+	}
+	return frozen
+}
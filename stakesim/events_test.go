@@ -0,0 +1,120 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"testing"
+
+	"github.com/decred/dcrd/chaincfg"
+)
+
+// TestScheduleEventsInverted ensures events that mix absolute and relative
+// heights are rejected with an ErrInvertedEvent error when they start after
+// they end once their heights are resolved, and are otherwise scheduled.
+func TestScheduleEventsInverted(t *testing.T) {
+	tests := []struct {
+		name      string
+		events    string
+		numBlocks uint64
+		inverted  bool
+		start     int32
+		end       int32
+	}{{
+		name:      "absolute start before relative end",
+		events:    "demand:1000-50%:2",
+		numBlocks: 10000,
+		start:     1000,
+		end:       5000,
+	}, {
+		name:      "absolute start after relative end",
+		events:    "demand:6000-50%:2",
+		numBlocks: 10000,
+		inverted:  true,
+	}, {
+		name:      "relative start before absolute end",
+		events:    "freeze:40%-60000:0.3",
+		numBlocks: 100000,
+		start:     40000,
+		end:       60000,
+	}, {
+		name:      "relative start after absolute end",
+		events:    "freeze:40%-60000:0.3",
+		numBlocks: 200000,
+		inverted:  true,
+	}, {
+		name:      "same resolved height",
+		events:    "stakecap:5000-50%:0.6",
+		numBlocks: 10000,
+		start:     5000,
+		end:       5000,
+	}}
+
+	for _, test := range tests {
+		events, err := ParseEvents(test.events)
+		if err != nil {
+			t.Errorf("%s: unable to parse events: %v", test.name, err)
+			continue
+		}
+		sim, err := New(&Config{
+			Params: &chaincfg.MainNetParams,
+			Events: events,
+		})
+		if err != nil {
+			t.Errorf("%s: unable to create simulator: %v", test.name,
+				err)
+			continue
+		}
+
+		err = sim.scheduleEvents(test.numBlocks)
+		if test.inverted {
+			serr, ok := err.(SimError)
+			if !ok || serr.ErrorCode != ErrInvertedEvent {
+				t.Errorf("%s: got error %v, want %v", test.name,
+					err, ErrInvertedEvent)
+			}
+			if sim.ScheduledEvents() != nil {
+				t.Errorf("%s: inverted event was scheduled",
+					test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		scheduled := sim.ScheduledEvents()
+		if len(scheduled) != 1 || scheduled[0].StartHeight != test.start ||
+			scheduled[0].EndHeight != test.end {
+
+			t.Errorf("%s: got scheduled events %v, want heights "+
+				"%d-%d", test.name, scheduled, test.start,
+				test.end)
+		}
+	}
+}
+
+// TestSimulateInvertedEvent ensures Simulate returns the error for an inverted
+// event before simulating any blocks.
+func TestSimulateInvertedEvent(t *testing.T) {
+	events, err := ParseEvents("demand:6000-50%:2")
+	if err != nil {
+		t.Fatalf("unable to parse events: %v", err)
+	}
+	sim, err := New(&Config{
+		Params: &chaincfg.MainNetParams,
+		Events: events,
+	})
+	if err != nil {
+		t.Fatalf("unable to create simulator: %v", err)
+	}
+
+	err = sim.Simulate(10000)
+	if serr, ok := err.(SimError); !ok || serr.ErrorCode != ErrInvertedEvent {
+		t.Fatalf("got error %v, want %v", err, ErrInvertedEvent)
+	}
+	if sim.Tip() != nil {
+		t.Fatalf("simulated blocks up to height %d", sim.Tip().Height())
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"strconv"
+)
+
+// blockRecord houses the details about a block in the simulated chain that are
+// exported for further analysis.  All amounts are in atoms.
+type blockRecord struct {
+	Height          int32  `json:"height"`
+	TicketPrice     int64  `json:"ticketPrice"`
+	PoolSize        uint32 `json:"poolSize"`
+	ImmatureTickets uint32 `json:"immatureTickets"`
+	RegularSubsidy  int64  `json:"regularSubsidy"`
+	TotalSupply     int64  `json:"totalSupply"`
+	SpendableSupply int64  `json:"spendableSupply"`
+	StakedCoins     int64  `json:"stakedCoins"`
+	NumVoters       uint16 `json:"numVoters"`
+	TicketsAdded    int    `json:"ticketsAdded"`
+	TicketsVoted    int    `json:"ticketsVoted"`
+	TicketsRevoked  int    `json:"ticketsRevoked"`
+}
+
+// blockRecordCSVHeader is the header line of exported CSV files.  The order
+// must match the fields returned by blockRecord.csvFields.
+var blockRecordCSVHeader = []string{"height", "ticketPrice", "poolSize",
+	"immatureTickets", "regularSubsidy", "totalSupply", "spendableSupply",
+	"stakedCoins", "numVoters", "ticketsAdded", "ticketsVoted",
+	"ticketsRevoked"}
+
+// newBlockRecord returns the exported details about the passed block node.
+func newBlockRecord(node *BlockNode) *blockRecord {
+	return &blockRecord{
+		Height:          node.height,
+		TicketPrice:     node.ticketPrice,
+		PoolSize:        node.poolSize,
+		ImmatureTickets: node.numImmature,
+		RegularSubsidy:  int64(node.regularSubsidy),
+		TotalSupply:     int64(node.totalSupply),
+		SpendableSupply: int64(node.spendableSupply),
+		StakedCoins:     int64(node.stakedCoins),
+		NumVoters:       node.numVoters,
+		TicketsAdded:    len(node.ticketsAdded),
+		TicketsVoted:    len(node.ticketsVoted),
+		TicketsRevoked:  len(node.ticketsRevoked),
+	}
+}
+
+// csvFields returns the fields of the record formatted for a CSV file in the
+// order defined by blockRecordCSVHeader.
+func (r *blockRecord) csvFields() []string {
+	return []string{
+		strconv.FormatInt(int64(r.Height), 10),
+		strconv.FormatInt(r.TicketPrice, 10),
+		strconv.FormatUint(uint64(r.PoolSize), 10),
+		strconv.FormatUint(uint64(r.ImmatureTickets), 10),
+		strconv.FormatInt(r.RegularSubsidy, 10),
+		strconv.FormatInt(r.TotalSupply, 10),
+		strconv.FormatInt(r.SpendableSupply, 10),
+		strconv.FormatInt(r.StakedCoins, 10),
+		strconv.FormatUint(uint64(r.NumVoters), 10),
+		strconv.Itoa(r.TicketsAdded),
+		strconv.Itoa(r.TicketsVoted),
+		strconv.Itoa(r.TicketsRevoked),
+	}
+}
+
+// ExportCSV writes the details about every block in the simulated chain, from
+// the root to the tip, to a CSV file at the provided path.
+func ExportCSV(s *Simulator, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write(blockRecordCSVHeader); err != nil {
+		return err
+	}
+	for node := s.root; node != nil; node = node.next {
+		if err := w.Write(newBlockRecord(node).csvFields()); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// ExportJSON writes the details about every block in the simulated chain, from
+// the root to the tip, to a file at the provided path as newline-delimited
+// JSON.  That is to say each line of the file is a JSON object for a block.
+func ExportJSON(s *Simulator, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for node := s.root; node != nil; node = node.next {
+		if err := enc.Encode(newBlockRecord(node)); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,1646 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"math/rand"
+	"sort"
+
+	"github.com/davecgh/dcrstakesim/internal/tickettreap"
+
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrutil"
+)
+
+var (
+	// hash256prngSeedConst is a constant derived from the hex
+	// representation of pi and is used in conjuction with a caller-provided
+	// seed when initializing the deterministic lottery prng.
+	hash256prngSeedConst = []byte{0x24, 0x3f, 0x6a, 0x88, 0x85, 0xa3, 0x08,
+		0xd3}
+)
+
+func init() {
+	RegisterPriceFunc(&PriceFunc{
+		Key:          "current",
+		Name:         "Current algorithm",
+		NewAlgorithm: statelessAlgorithm(curCalcNextStakeDiff),
+	})
+}
+
+// hash256prng is a determinstic pseudorandom number generator that uses a
+// 256-bit secure hashing function to generate random uint32s starting from
+// an initial seed.
+type hash256prng struct {
+	seed       chainhash.Hash // Initialization seed
+	idx        uint64         // Hash iterator index
+	cachedHash chainhash.Hash // Most recently generated hash
+	hashOffset int            // Offset into most recently generated hash
+}
+
+// newHash256PRNG creates a pointer to a newly created hash256PRNG.
+func newHash256PRNG(seed []byte) *hash256prng {
+	// The provided seed is initialized by appending a constant derived from
+	// the hex representation of pi and hashing the result to give 32 bytes.
+	// This ensures the PRNG is always doing a short number of rounds
+	// regardless of input since it will only need to hash small messages
+	// (less than 64 bytes).
+	seedHash := chainhash.HashFunc(append(seed, hash256prngSeedConst...))
+	return &hash256prng{
+		seed:       seedHash,
+		idx:        0,
+		cachedHash: seedHash,
+	}
+}
+
+// Hash256Rand returns a uint32 random number using the pseudorandom number
+// generator and updates the state.
+func (hp *hash256prng) Hash256Rand() uint32 {
+	offset := hp.hashOffset * 4
+	r := binary.BigEndian.Uint32(hp.cachedHash[offset : offset+4])
+	hp.hashOffset++
+
+	// Generate a new hash and reset the hash position index once it would
+	// overflow the available bytes in the most recently generated hash.
+	if hp.hashOffset > 7 {
+		// Hash of the seed concatenated with the hash iterator index.
+		//   hash(hp.seed || hp.idx)
+		data := make([]byte, len(hp.seed)+4)
+		copy(data, hp.seed[:])
+		binary.BigEndian.PutUint32(data[len(hp.seed):], uint32(hp.idx))
+		hp.cachedHash = chainhash.HashH(data)
+		hp.idx++
+		hp.hashOffset = 0
+	}
+
+	// Roll over the entire PRNG by re-hashing the seed when the hash
+	// iterator index overlows a uint32.
+	if hp.idx > math.MaxUint32 {
+		hp.seed = chainhash.HashH(hp.seed[:])
+		hp.cachedHash = hp.seed
+		hp.idx = 0
+	}
+
+	return r
+}
+
+// uniformRandom returns a random in the range [0, upperBound) while avoiding
+// modulo bias to ensure a normal distribution within the specified range.
+func (hp *hash256prng) uniformRandom(upperBound uint32) uint32 {
+	if upperBound < 2 {
+		return 0
+	}
+
+	// (2^32 - (x*2)) % x == 2^32 % x when x <= 2^31
+	min := ((math.MaxUint32 - (upperBound * 2)) + 1) % upperBound
+	if upperBound > 0x80000000 {
+		min = 1 + ^upperBound
+	}
+
+	r := hp.Hash256Rand()
+	for r < min {
+		r = hp.Hash256Rand()
+	}
+	return r % upperBound
+}
+
+// StakeTicket represents a simulated sstx (stake ticket) along with the height
+// of the block it was simulated to be mined in and the height it wins in.
+type StakeTicket struct {
+	hash        chainhash.Hash
+	blockHeight int32
+	price       dcrutil.Amount
+	winHeight   int32
+
+	// revocableHeight is the height at which the ticket missed its vote or
+	// expired and thus became eligible to be revoked, while revokeHeight
+	// is the height at which the owner of the ticket revokes it.
+	revocableHeight int32
+	revokeHeight    int32
+
+	// vsp is the voting service provider the ticket is delegated to or nil
+	// when it is held by a solo staker.
+	vsp *VotingService
+}
+
+// newStakeTicket returns a new simulated stake ticket with the given hash and
+// purchased at the provided height.
+func newStakeTicket(hash *chainhash.Hash, purchaseHeight int32, price int64) *StakeTicket {
+	return &StakeTicket{
+		hash:        *hash,
+		blockHeight: purchaseHeight,
+		price:       dcrutil.Amount(price),
+		winHeight:   -1,
+	}
+}
+
+// Hash returns the hash of the ticket.
+func (t *StakeTicket) Hash() chainhash.Hash {
+	return t.hash
+}
+
+// PurchaseHeight returns the height of the block the ticket was purchased in.
+func (t *StakeTicket) PurchaseHeight() int32 {
+	return t.blockHeight
+}
+
+// Price returns the price the ticket was purchased for.
+func (t *StakeTicket) Price() dcrutil.Amount {
+	return t.price
+}
+
+// WinHeight returns the height at which the ticket won the lottery or -1 when
+// it has not won.
+func (t *StakeTicket) WinHeight() int32 {
+	return t.winHeight
+}
+
+// RevocableHeight returns the height at which the ticket missed its vote or
+// expired and thus became eligible to be revoked.
+func (t *StakeTicket) RevocableHeight() int32 {
+	return t.revocableHeight
+}
+
+// RevokeHeight returns the height at which the owner of the ticket revokes it.
+func (t *StakeTicket) RevokeHeight() int32 {
+	return t.revokeHeight
+}
+
+// VSP returns the voting service provider the ticket is delegated to or nil
+// when it is held by a solo staker.
+func (t *StakeTicket) VSP() *VotingService {
+	return t.vsp
+}
+
+// stakeTicketHash generates a fake, but deterministic, stake ticket hash based
+// on the height the ticket is purchased at as well as its position within the
+// simulated block.
+func stakeTicketHash(purchaseHeight int32, ticketNum uint8) chainhash.Hash {
+	var b [8]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(purchaseHeight))
+	binary.LittleEndian.PutUint32(b[4:], uint32(ticketNum))
+	return chainhash.HashH(b[:])
+}
+
+// uint32Sorter implements sort.Interface to allow a slice of 32-bit unsigned
+// integers to be sorted.
+type uint32Sorter []uint32
+
+// Len returns the number of 32-bit unsigned integers in the slice.  It is part
+// of the sort.Interface implementation.
+func (s uint32Sorter) Len() int {
+	return len(s)
+}
+
+// Swap swaps the 32-bit unsigned integers at the passed indices.  It is part of
+// the sort.Interface implementation.
+func (s uint32Sorter) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// Less returns whether the 64-bit integer with index i should sort before the
+// 32-bit unsigned integer with index j.  It is part of the sort.Interface
+// implementation.
+func (s uint32Sorter) Less(i, j int) bool {
+	return s[i] < s[j]
+}
+
+// winningTickets returns a slice of tickets that are required to vote for the
+// given block being voted on and current live ticket pool.
+func winningTickets(voteBlock *BlockNode, liveTickets *tickettreap.Immutable, numVotes uint16) ([]*StakeTicket, error) {
+	// Ensure the number of live tickets is within the allowable range.
+	numLiveTickets := uint32(liveTickets.Len())
+	if numLiveTickets > math.MaxUint32 {
+		return nil, fmt.Errorf("live ticket pool has %d tickets which "+
+			"is more than the max allowed of %d", numLiveTickets,
+			math.MaxUint32)
+	}
+	if uint32(numVotes) > numLiveTickets {
+		return nil, fmt.Errorf("live ticket pool has %d tickets, "+
+			"while %d are needed to vote", numLiveTickets, numVotes)
+	}
+
+	// Construct list of winners by generating successive values from the
+	// deterministic prng and using them as indices into the sorted live
+	// ticket pool while skipping any duplicates that might occur.
+	prng := newHash256PRNG(voteBlock.header)
+	winningOffsets := make([]uint32, 0, numVotes)
+	usedOffsets := make(map[uint32]struct{})
+	for uint16(len(winningOffsets)) < numVotes {
+		ticketIndex := prng.uniformRandom(numLiveTickets)
+		if _, exists := usedOffsets[ticketIndex]; !exists {
+			usedOffsets[ticketIndex] = struct{}{}
+			winningOffsets = append(winningOffsets, ticketIndex)
+		}
+	}
+	sort.Sort(uint32Sorter(winningOffsets))
+
+	// Reconstruct the winning stake tickets based upon the winning indices.
+	winners := make([]*StakeTicket, 0, numVotes)
+	var poolIdx, winnerIdx uint32
+	liveTickets.ForEach(func(key tickettreap.Key, val *tickettreap.Value) bool {
+		if poolIdx == winningOffsets[winnerIdx] {
+			ticketHash := (*chainhash.Hash)(&key)
+			ticket := newStakeTicket(ticketHash, val.PurchaseHeight,
+				val.PurchasePrice)
+			ticket.winHeight = voteBlock.height + 1
+			winners = append(winners, ticket)
+			if uint16(len(winners)) == numVotes {
+				return false
+			}
+			winnerIdx++
+		}
+		poolIdx++
+		return true
+	})
+
+	return winners, nil
+}
+
+// BlockNode represent a block in the simulated chain along with additional data
+// about the block calculated during the simulation.
+type BlockNode struct {
+	parent *BlockNode
+	height int32
+	header []byte
+	next   *BlockNode
+
+	ticketPrice     int64          // Stake difficulty target.
+	regularSubsidy  dcrutil.Amount // PoW and dev subsidies of this block.
+	poolSize        uint32         // Total pool size as of this block.
+	totalSupply     dcrutil.Amount // Total supply as of this block.
+	spendableSupply dcrutil.Amount // Spendable supply as of this block.
+
+	// stakedCoins is the amount of coins that are staked as of this block.
+	// It does not consider maturity periods since even though coins that
+	// have recently become unlocked aren't spendable until they mature,
+	// those coins are not staked.
+	stakedCoins dcrutil.Amount
+
+	// numImmature is the number of immature tickets as of this block.
+	numImmature uint32
+
+	// mempoolDepth is the number of ticket purchases still waiting in the
+	// simulated mempool after this block when it is enabled.
+	mempoolDepth uint32
+
+	numVoters      uint16
+	ticketsAdded   []*StakeTicket
+	ticketsVoted   []*StakeTicket
+	ticketsRevoked []*StakeTicket
+}
+
+// newBlockNode returns a new simulated block node the is connected to the
+// provided parent node and is populated with the provided params.
+func newBlockNode(parent *BlockNode, ticketsAdded, ticketsVoted, ticketsRevoked []*StakeTicket) *BlockNode {
+	node := &BlockNode{
+		parent:         parent,
+		ticketsAdded:   ticketsAdded,
+		ticketsVoted:   ticketsVoted,
+		ticketsRevoked: ticketsRevoked,
+	}
+	if parent != nil {
+		parent.next = node
+		node.height = parent.height + 1
+	}
+	return node
+}
+
+// Parent returns the previous block or nil for the first block.
+func (node *BlockNode) Parent() *BlockNode {
+	return node.parent
+}
+
+// Next returns the following block or nil for the tip.
+func (node *BlockNode) Next() *BlockNode {
+	return node.next
+}
+
+// Height returns the height of the block.
+func (node *BlockNode) Height() int32 {
+	return node.height
+}
+
+// Header returns the header bytes of the block.
+func (node *BlockNode) Header() []byte {
+	return node.header
+}
+
+// TicketPrice returns the stake difficulty of the block.
+func (node *BlockNode) TicketPrice() int64 {
+	return node.ticketPrice
+}
+
+// RegularSubsidy returns the proof-of-work and dev subsidies of the block.
+func (node *BlockNode) RegularSubsidy() dcrutil.Amount {
+	return node.regularSubsidy
+}
+
+// PoolSize returns the size of the live ticket pool as of the block.
+func (node *BlockNode) PoolSize() uint32 {
+	return node.poolSize
+}
+
+// TotalSupply returns the total coin supply as of the block.
+func (node *BlockNode) TotalSupply() dcrutil.Amount {
+	return node.totalSupply
+}
+
+// SpendableSupply returns the spendable coin supply as of the block.
+func (node *BlockNode) SpendableSupply() dcrutil.Amount {
+	return node.spendableSupply
+}
+
+// StakedCoins returns the amount of coins that are staked as of the block.
+func (node *BlockNode) StakedCoins() dcrutil.Amount {
+	return node.stakedCoins
+}
+
+// NumImmature returns the number of immature tickets as of the block.
+func (node *BlockNode) NumImmature() uint32 {
+	return node.numImmature
+}
+
+// MempoolDepth returns the number of ticket purchases left waiting in the
+// simulated mempool after the block.
+func (node *BlockNode) MempoolDepth() uint32 {
+	return node.mempoolDepth
+}
+
+// NumVoters returns the number of votes included in the block.
+func (node *BlockNode) NumVoters() uint16 {
+	return node.numVoters
+}
+
+// TicketsAdded returns the tickets purchased in the block.
+func (node *BlockNode) TicketsAdded() []*StakeTicket {
+	return node.ticketsAdded
+}
+
+// TicketsVoted returns the tickets that voted in the block.
+func (node *BlockNode) TicketsVoted() []*StakeTicket {
+	return node.ticketsVoted
+}
+
+// TicketsRevoked returns the tickets revoked in the block.
+func (node *BlockNode) TicketsRevoked() []*StakeTicket {
+	return node.ticketsRevoked
+}
+
+// Simulator provides a proof-of-stake simulation framework for Decred which
+// includes a live ticket pool that works with ticket maturity, purchasing,
+// revocation, expiration, and winning ticket selection along with ticket price
+// calculation.  It also provides some other features such as coin supply
+// calculation.
+type Simulator struct {
+	params *chaincfg.Params
+
+	// log is where additional details about the simulation are written.
+	// They are not written when nil.
+	log io.Writer
+
+	// progress is where the progress of the simulation is reported.  It
+	// is not reported when nil.
+	progress io.Writer
+
+	// seed is mixed into the generated block headers in order to vary the
+	// winning tickets selected by the lottery between simulations that are
+	// otherwise identical.  A seed of zero produces the same headers as
+	// older versions of the simulator.
+	seed int64
+
+	// rng is the source of randomness for the behavioral models of the
+	// simulation.  It is seeded from the seed so that simulations are
+	// reproducible.  rngSource is the source it draws from which keeps
+	// track of its state for snapshots.
+	rng       *rand.Rand
+	rngSource *countingSource
+
+	// autoRevocationsHeight is the height at which missed and expired
+	// tickets start being automatically revoked in the same block per
+	// DCP0009.  It is disabled when negative.
+	autoRevocationsHeight int32
+
+	// stakeCap is the maximum fraction of the total supply that will be
+	// staked when simulating ticket purchases.
+	stakeCap float64
+
+	// events are the configured events that change ticket purchasing
+	// behavior during the simulation and scheduledEvents are those same
+	// events with their heights resolved for the number of blocks being
+	// simulated.  freezeTargets are the amounts of staked coins purchases
+	// are frozen until by each freeze event that has started.
+	events          []Event
+	scheduledEvents []*ScheduledEvent
+	freezeTargets   map[*ScheduledEvent]dcrutil.Amount
+
+	// noiseState is the current perturbation of the demand applied by the
+	// mean-reverting noise model.
+	noiseState float64
+
+	// outageEndHeight is the final height of the current outage simulated
+	// by the outage miss model.
+	outageEndHeight int32
+
+	// purchaseBacklog is the number of tickets demanded in the current
+	// window which did not fit in the previous blocks.
+	purchaseBacklog int32
+
+	// mempool houses the ticket purchases which compete for the new
+	// tickets allowed in each block when it is enabled.
+	mempool *ticketMempool
+
+	// agents are the stakeholders which make the ticket purchase decisions
+	// instead of the demand func when they are enabled.
+	agents *agentPopulation
+
+	// vsps are the voting service providers that tickets are delegated to
+	// when they are simulated.  Since the live ticket pool only tracks the
+	// purchase details of the tickets, ticketVSPs tracks the VSP each live
+	// ticket is delegated to.
+	vsps       []*VotingService
+	ticketVSPs map[chainhash.Hash]*VotingService
+
+	// verify houses the results of verifying the simulated blocks against
+	// the block headers of the CSV input data when it is enabled.
+	verify *VerifySummary
+
+	// The fields are related to the simulated chain.
+	root *BlockNode
+	tip  *BlockNode
+
+	// These fields are related to tracking tickets as they enter and exit
+	// the live ticket pool due to events such as maturing, winning the
+	// lottery, failing to vote, and expiring.
+	immatureTickets  []*StakeTicket
+	liveTickets      *tickettreap.Immutable
+	expireHeights    map[int32][]*StakeTicket
+	expiredTickets   []*StakeTicket
+	missedTickets    []*StakeTicket
+	unrevokedTickets []*StakeTicket
+	wonTickets       []*StakeTicket
+
+	// maturingSupply keeps track of how much coin supply will mature at
+	// each height.
+	maturingSupply map[int32]dcrutil.Amount
+
+	// These fields control the ticket price algorithm and demand
+	// distribution function used in the simulation.  The demand func takes
+	// the next height and the ticket price produced by the algorithm.
+	stakeDiffAlgorithm StakeDiffAlgorithm
+	demandFunc         func(int32, int64) float64
+
+	// purchaseProfileFunc returns how many of the passed number of tickets
+	// demanded for a window are purchased in the block at the passed
+	// position within the window.
+	purchaseProfileFunc func(int32, int32) int32
+
+	// noiseFunc returns the passed demand produced by the demand func with
+	// random noise applied.
+	noiseFunc func(float64) float64
+
+	// missFunc returns how many of the passed number of winning tickets
+	// for the next height miss their vote.
+	missFunc func(int32, uint16) uint16
+
+	// revokeFunc returns the height at which the passed ticket, which
+	// became eligible to be revoked at the passed height, is revoked.
+	revokeFunc func(*StakeTicket, int32) int32
+}
+
+// CalcFullSubsidy returns the full block subsidy for the given block height.
+func (s *Simulator) CalcFullSubsidy(blockHeight int32) dcrutil.Amount {
+	iterations := int64(blockHeight) / s.params.SubsidyReductionInterval
+	subsidy := s.params.BaseSubsidy
+	for i := int64(0); i < iterations; i++ {
+		subsidy *= s.params.MulSubsidy
+		subsidy /= s.params.DivSubsidy
+	}
+	return dcrutil.Amount(subsidy)
+}
+
+// CalcPoWSubsidy returns the proof-of-work subsidy portion from a given full
+// subsidy, block height, and number of votes that will be included in the
+// block.
+func (s *Simulator) CalcPoWSubsidy(fullSubsidy dcrutil.Amount, blockHeight int32, numVotes uint16) dcrutil.Amount {
+	powProportion := dcrutil.Amount(s.params.WorkRewardProportion)
+	totalProportions := dcrutil.Amount(s.params.TotalSubsidyProportions())
+	powSubsidy := (fullSubsidy * powProportion) / totalProportions
+	if int64(blockHeight) < s.params.StakeValidationHeight {
+		return powSubsidy
+	}
+
+	// Reduce the subsidy according to the number of votes.
+	ticketsPerBlock := dcrutil.Amount(s.params.TicketsPerBlock)
+	return (powSubsidy * dcrutil.Amount(numVotes)) / ticketsPerBlock
+}
+
+// CalcPoSSubsidy returns the proof-of-stake subsidy portion for a given block
+// height being voted on.
+func (s *Simulator) CalcPoSSubsidy(heightVotedOn int32) dcrutil.Amount {
+	if int64(heightVotedOn+1) < s.params.StakeValidationHeight {
+		return 0
+	}
+
+	fullSubsidy := s.CalcFullSubsidy(heightVotedOn)
+	posProportion := dcrutil.Amount(s.params.StakeRewardProportion)
+	totalProportions := dcrutil.Amount(s.params.TotalSubsidyProportions())
+	return (fullSubsidy * posProportion) / totalProportions
+}
+
+// CalcDevSubsidy returns the dev org subsidy portion from a given full subsidy.
+func (s *Simulator) CalcDevSubsidy(fullSubsidy dcrutil.Amount, blockHeight int32, numVotes uint16) dcrutil.Amount {
+	devProportion := dcrutil.Amount(s.params.BlockTaxProportion)
+	totalProportions := dcrutil.Amount(s.params.TotalSubsidyProportions())
+	devSubsidy := (fullSubsidy * devProportion) / totalProportions
+	if int64(blockHeight) < s.params.StakeValidationHeight {
+		return devSubsidy
+	}
+
+	// Reduce the subsidy according to the number of votes.
+	ticketsPerBlock := dcrutil.Amount(s.params.TicketsPerBlock)
+	return (devSubsidy * dcrutil.Amount(numVotes)) / ticketsPerBlock
+}
+
+// ancestorNode returns the ancestor node at the provided height by following
+// the chain backwards from the given node.  The returned node will be nil when
+// a height is requested that is after the height of the passed node.  Also, a
+// callback can optionally be provided that is invoked with each node as it
+// traverses.
+func (s *Simulator) ancestorNode(node *BlockNode, height int32, f func(*BlockNode)) *BlockNode {
+	// Nothing to do if the requested height is outside of the valid
+	// range.
+	if node == nil || height > node.height {
+		return nil
+	}
+
+	// Iterate backwards until the requested height is reached.
+	for node != nil && node.height > height {
+		node = node.parent
+		if f != nil && node != nil {
+			f(node)
+		}
+	}
+
+	return node
+}
+
+// mergeDifficulty takes an original stake difficulty and two new, scaled
+// stake difficulties, merges the new difficulties, and outputs a new
+// merged stake difficulty.
+func mergeDifficulty(oldDiff int64, newDiff1 int64, newDiff2 int64) int64 {
+	newDiff1Big := big.NewInt(newDiff1)
+	newDiff2Big := big.NewInt(newDiff2)
+	newDiff2Big.Lsh(newDiff2Big, 32)
+
+	oldDiffBig := big.NewInt(oldDiff)
+	oldDiffBigLSH := big.NewInt(oldDiff)
+	oldDiffBigLSH.Lsh(oldDiffBig, 32)
+
+	newDiff1Big.Div(oldDiffBigLSH, newDiff1Big)
+	newDiff2Big.Div(newDiff2Big, oldDiffBig)
+
+	// Combine the two changes in difficulty.
+	summedChange := big.NewInt(0)
+	summedChange.Set(newDiff2Big)
+	summedChange.Lsh(summedChange, 32)
+	summedChange.Div(summedChange, newDiff1Big)
+	summedChange.Mul(summedChange, oldDiffBig)
+	summedChange.Rsh(summedChange, 32)
+
+	return summedChange.Int64()
+}
+
+// limitRetarget clamps the passed new difficulty to the old one adjusted by the
+// factor specified in the passed chain parameters.  This ensures the difficulty can
+// only move up or down by a limited amount.
+func limitRetarget(params *chaincfg.Params, oldDiff, newDiff int64) int64 {
+	maxRetarget := params.RetargetAdjustmentFactor
+	switch {
+	case newDiff == 0:
+		fallthrough
+	case (oldDiff / newDiff) > (maxRetarget - 1):
+		return oldDiff / maxRetarget
+	case (newDiff / oldDiff) > (maxRetarget - 1):
+		return oldDiff * maxRetarget
+	}
+
+	return newDiff
+}
+
+// curCalcNextStakeDiff returns the required stake difficulty (aka ticket price)
+// for the block after the tip of the passed chain view using the current
+// algorithm deployed on mainnet as of Mar 2017.
+//
+// An overview of the algorithm is as follows:
+// 1) Use the minimum value for any blocks before any tickets could have
+//    possibly been purchased due to coinbase maturity requirements
+// 2) Return 0 if the current tip block stake difficulty is 0.  This is a
+//    safety check against a condition that should never actually happen.
+// 3) Use the previous block's difficulty if the next block is not at a retarget
+//    interval
+// 4) Calculate the ideal retarget difficulty for each window based on the
+//    actual pool size in the window versus the target pool size skewed by a
+//    constant factor to weight the ticket pool size instead of the tickets per
+//    block and exponentially weight each difficulty such that the most recent
+//    window has the highest weight
+// 5) Calculate the pool size retarget difficulty based on the exponential
+//    weighted average and ensure it is limited to the max retarget adjustment
+//    factor -- This is the first metric used to calculate the final difficulty
+// 6) Calculate the ideal retarget difficulty for each window based on the
+//    actual new tickets in the window versus the target new tickets per window
+//    and exponentially weight each difficulty such that the most recent window
+//    has the highest weight
+// 7) Calculate the tickets per window retarget difficulty based on the
+//    exponential weighted average and ensure it is limited to the max retarget
+//    adjustment factor
+// 8) Calculate the final difficulty by averaging the pool size retarget
+//    difficulty from #5 and the tickets per window retarget difficulty from #7
+//    using scaled multiplication and ensure it is limited to the max retarget
+//    adjustment factor
+func curCalcNextStakeDiff(chain ChainView) int64 {
+	params, tip := chain.Params(), chain.Tip()
+
+	// Stake difficulty before any tickets could possibly be purchased is
+	// the minimum value.
+	nextHeight := int32(0)
+	if tip != nil {
+		nextHeight = tip.Height + 1
+	}
+	stakeDiffStartHeight := int32(params.CoinbaseMaturity) + 1
+	if nextHeight < stakeDiffStartHeight {
+		return params.MinimumStakeDiff
+	}
+
+	// Return 0 if the current difficulty is already zero since any scaling
+	// of 0 is still 0.  This should never really happen since there is a
+	// minimum stake difficulty, but the consensus code checks the condition
+	// just in case, so follow suit here.
+	curDiff := tip.TicketPrice
+	if curDiff == 0 {
+		return 0
+	}
+
+	// Return the previous block's difficulty requirements if the next block
+	// is not at a difficulty retarget interval.
+	windowSize := params.StakeDiffWindowSize
+	if int64(nextHeight)%windowSize != 0 {
+		return curDiff
+	}
+
+	// --------------------------------
+	// Ideal pool size retarget metric.
+	// --------------------------------
+
+	// Calculate the ideal retarget difficulty for each window based on the
+	// actual pool size in the window versus the target pool size and
+	// exponentially weight them.
+	var weightSum int64
+	adjusted, weightedPoolSizeSum := new(big.Int), new(big.Int)
+	ticketsPerBlock := int64(params.TicketsPerBlock)
+	targetPoolSize := ticketsPerBlock * int64(params.TicketPoolSize)
+	targetpoolSizeBig := big.NewInt(targetPoolSize)
+	numWindows := params.StakeDiffWindows
+	weightAlpha := params.StakeDiffAlpha
+	for i := int64(0); i < numWindows; i++ {
+		// Get the pool size for the block at the start of the window.
+		// Use zero if there are not yet enough blocks left to cover the
+		// window.
+		prevRetargetHeight := nextHeight - int32(windowSize*(i+1))
+		windowPoolSize := int64(0)
+		if node := chain.Ancestor(prevRetargetHeight); node != nil {
+			windowPoolSize = int64(node.PoolSize)
+		}
+
+		// Skew the pool size by the constant weight factor specified in
+		// the chain parameters (which is typically the max adjustment
+		// factor) in order to help weight the ticket pool size versus
+		// tickets per block.  Also, ensure the skewed pool size is a
+		// minimum of 1.
+		skewedPoolSize := targetPoolSize + (windowPoolSize-
+			targetPoolSize)*int64(params.TicketPoolSizeWeight)
+		if skewedPoolSize <= 0 {
+			skewedPoolSize = 1
+		}
+
+		// Calculate the ideal retarget difficulty for the window based
+		// on the skewed pool size and weight it exponentially by
+		// multiplying it by 2^(window_number) such that the most recent
+		// window receives the most weight.
+		//
+		// Also, since integer division is being used, shift up the
+		// number of new tickets 32 bits to avoid losing precision.
+		//
+		//   adjusted = (skewedPoolSize << 32) / targetPoolSize
+		//   adjusted = adjusted << (numWindows-i)*weightAlpha
+		//   weightedPoolSizeSum += adjusted
+		//
+		adjusted.SetInt64(skewedPoolSize)
+		adjusted.Lsh(adjusted, 32)
+		adjusted.Div(adjusted, targetpoolSizeBig)
+		adjusted.Lsh(adjusted, uint((numWindows-i)*weightAlpha))
+		weightedPoolSizeSum.Add(weightedPoolSizeSum, adjusted)
+		weightSum += 1 << uint64((numWindows-i)*weightAlpha)
+	}
+
+	// Calculate the pool size retarget difficulty based on the exponential
+	// weighted average and shift the result back down 32 bits to account
+	// for the previous shift up in order to avoid losing precision.  Then,
+	// limit it to the maximum allowed retarget adjustment factor.
+	//
+	// This is the first metric used in the final calculated difficulty.
+	//
+	//  nextPoolSizeDiff = ((weightedPoolSizeSum/weightSum) * curDiff) >> 32
+	//
+	curDiffBig := big.NewInt(curDiff)
+	weightSumBig := big.NewInt(weightSum)
+	weightedPoolSizeSum.Div(weightedPoolSizeSum, weightSumBig)
+	weightedPoolSizeSum.Mul(weightedPoolSizeSum, curDiffBig)
+	weightedPoolSizeSum.Rsh(weightedPoolSizeSum, 32)
+	nextPoolSizeDiff := weightedPoolSizeSum.Int64()
+	nextPoolSizeDiff = limitRetarget(params, curDiff, nextPoolSizeDiff)
+
+	// -----------------------------------------
+	// Ideal tickets per window retarget metric.
+	// -----------------------------------------
+
+	// Calculate the ideal retarget difficulty for each window based on the
+	// actual number of new tickets in the window versus the target tickets
+	// per window and exponentially weight them.
+	weightedTicketsSum := big.NewInt(0)
+	targetTicketsPerWindow := ticketsPerBlock * windowSize
+	targetTicketsPerWindowBig := big.NewInt(targetTicketsPerWindow)
+	for i := int64(0); i < numWindows; i++ {
+		// Tally all of the new tickets in all blocks in the window and
+		// ensure the number of new tickets is a minimum of 1.  Since the
+		// difficulty for the next block after the current tip is being
+		// calculated and there is no such block yet, the first window
+		// ends with the tip block.
+		prevRetargetHeight := nextHeight - int32(windowSize*(i+1))
+		windowNewTickets := chain.NewTickets(prevRetargetHeight,
+			nextHeight-int32(windowSize*i)-1)
+		if windowNewTickets <= 0 {
+			windowNewTickets = 1
+		}
+
+		// Calculate the ideal retarget difficulty for the window based
+		// on the number of new tickets and weight it exponentially by
+		// multiplying it by 2^(window_number) such that the most recent
+		// window receives the most weight.
+		//
+		// Also, since integer division is being used, shift up the
+		// number of new tickets 32 bits to avoid losing precision.
+		//
+		//  adjusted = (windowNewTickets << 32) / targetTicketsPerWindow
+		//  adjusted = adjusted << (numWindows-i)*weightAlpha
+		//  weightedTicketsSum += adjusted
+		//
+		adjusted.SetInt64(windowNewTickets)
+		adjusted.Lsh(adjusted, 32)
+		adjusted.Div(adjusted, targetTicketsPerWindowBig)
+		adjusted.Lsh(adjusted, uint((numWindows-i)*weightAlpha))
+		weightedTicketsSum.Add(weightedTicketsSum, adjusted)
+	}
+
+	// Calculate the tickets per window retarget difficulty based on the
+	// exponential weighted average and shift the result back down 32 bits
+	// to account for the previous shift up in order to avoid losing
+	// precision.  Then, limit it to the maximum allowed retarget adjustment
+	// factor.
+	//
+	// This is the second metric used in the final calculated difficulty.
+	//
+	// nextNewTixDiff = ((weightedTicketsSum/weightSum) * curDiff) >> 32
+	//
+	weightedTicketsSum.Div(weightedTicketsSum, weightSumBig)
+	weightedTicketsSum.Mul(weightedTicketsSum, curDiffBig)
+	weightedTicketsSum.Rsh(weightedTicketsSum, 32)
+	nextNewTixDiff := weightedTicketsSum.Int64()
+	nextNewTixDiff = limitRetarget(params, curDiff, nextNewTixDiff)
+
+	// Average the previous two metrics using scaled multiplication and
+	// ensure the result is limited to both the maximum allowed retarget
+	// adjustment factor and the minimum allowed stake difficulty.
+	nextDiff := mergeDifficulty(curDiff, nextPoolSizeDiff, nextNewTixDiff)
+	nextDiff = limitRetarget(params, curDiff, nextDiff)
+	if nextDiff < params.MinimumStakeDiff {
+		return params.MinimumStakeDiff
+	}
+	return nextDiff
+}
+
+// removeTicket removes the passed index from the provided slice of tickets and
+// returns the resulting slice.  This is an in-place modification.
+func removeTicket(tickets []*StakeTicket, index int) []*StakeTicket {
+	copy(tickets[index:], tickets[index+1:])
+	tickets[len(tickets)-1] = nil // Prevent memory leak
+	tickets = tickets[:len(tickets)-1]
+	return tickets
+}
+
+// addUnrevokedTicket adds the provided ticket, which missed its vote or
+// expired at the given height, to the unrevoked tickets pool along with the
+// height at which it will be revoked according to the revocation model.
+func (s *Simulator) addUnrevokedTicket(ticket *StakeTicket, height int32) {
+	ticket.revocableHeight = height
+	ticket.revokeHeight = s.revokeFunc(ticket, height)
+	s.unrevokedTickets = append(s.unrevokedTickets, ticket)
+}
+
+// numRevocations returns the number of unrevoked tickets the revocation model
+// revokes in the block at the provided height.
+func (s *Simulator) numRevocations(height int32) uint16 {
+	var count uint16
+	for _, ticket := range s.unrevokedTickets {
+		if ticket.revokeHeight <= height {
+			count++
+		}
+	}
+	return count
+}
+
+// isAutoRevocationsActive returns whether or not missed and expired tickets
+// are automatically revoked in the same block per DCP0009 as of the provided
+// height.
+func (s *Simulator) isAutoRevocationsActive(height int32) bool {
+	return s.autoRevocationsHeight >= 0 && height >= s.autoRevocationsHeight
+}
+
+// autoRevokedTickets returns the tickets that are automatically revoked in the
+// block at the provided height once automatic revocations are active, which
+// are the provided missed tickets along with the live tickets that expire at
+// that height without having won.
+func (s *Simulator) autoRevokedTickets(height int32, ticketsMissed []*StakeTicket) []*StakeTicket {
+	revoked := append([]*StakeTicket(nil), ticketsMissed...)
+	for _, ticket := range s.expireHeights[height] {
+		if ticket.winHeight != height &&
+			s.liveTickets.Has(tickettreap.Key(ticket.hash)) {
+
+			revoked = append(revoked, ticket)
+		}
+	}
+	return revoked
+}
+
+// numAutoRevocations returns the number of tickets that are automatically
+// revoked in the block after the current tip when it includes the provided
+// number of votes.  It is used to determine how many of the revocations in
+// real block headers were performed automatically.
+func (s *Simulator) numAutoRevocations(numVotes uint16) (uint16, error) {
+	var nextHeight int32
+	if s.tip != nil {
+		nextHeight = s.tip.height + 1
+	}
+	if !s.isAutoRevocationsActive(nextHeight) {
+		return 0, nil
+	}
+
+	var ticketsMissed []*StakeTicket
+	if int64(nextHeight) >= s.params.StakeValidationHeight {
+		winners, err := winningTickets(s.tip, s.liveTickets,
+			s.params.TicketsPerBlock)
+		if err != nil {
+			return 0, err
+		}
+		if int(numVotes) > len(winners) {
+			return 0, fmt.Errorf("%d votes at height %d is more "+
+				"than the %d winning tickets", numVotes,
+				nextHeight, len(winners))
+		}
+		ticketsMissed = winners[numVotes:]
+	}
+	return uint16(len(s.autoRevokedTickets(nextHeight, ticketsMissed))), nil
+}
+
+// connectLiveTickets updates the live ticket pool for a new tip block by
+// removing the provided winners and the tickets that are now expired and adding
+// any immature tickets which are now mature.
+func (s *Simulator) connectLiveTickets(height int32, winners, purchases []*StakeTicket) {
+	// Move winning tickets from the live ticket pool to won tickets pool.
+	for _, winner := range winners {
+		s.liveTickets = s.liveTickets.Delete(tickettreap.Key(winner.hash))
+		s.wonTickets = append(s.wonTickets, winner)
+		delete(s.ticketVSPs, winner.hash)
+	}
+
+	// Move expired tickets from the live ticket pool to the expired and
+	// unrevoked ticket pools.
+	tickets := s.expireHeights[height]
+	for _, ticket := range tickets {
+		if s.liveTickets.Has(tickettreap.Key(ticket.hash)) {
+			s.expiredTickets = append(s.expiredTickets, ticket)
+			if !s.isAutoRevocationsActive(height) {
+				s.addUnrevokedTicket(ticket, height)
+			}
+		}
+		s.liveTickets = s.liveTickets.Delete(tickettreap.Key(ticket.hash))
+		delete(s.ticketVSPs, ticket.hash)
+	}
+	delete(s.expireHeights, height)
+
+	// Move immature tickets which are now mature to the live ticket pool.
+	ticketMaturity := int32(s.params.TicketMaturity)
+	for i := 0; i < len(s.immatureTickets); i++ {
+		ticket := s.immatureTickets[i]
+		liveHeight := ticket.blockHeight + ticketMaturity
+		if height >= liveHeight {
+			s.immatureTickets = removeTicket(s.immatureTickets, i)
+			s.liveTickets = s.liveTickets.Put(
+				tickettreap.Key(ticket.hash),
+				&tickettreap.Value{
+					PurchaseHeight: ticket.blockHeight,
+					PurchasePrice:  int64(ticket.price),
+				})
+
+			// This is required because the ticket at the current
+			// offset was just removed from the slice that is being
+			// iterated, so adjust the offset down one accordingly.
+			i--
+		}
+	}
+
+	// Add new ticket purchases to the immature ticket pool.
+	s.immatureTickets = append(s.immatureTickets, purchases...)
+
+	// Add new ticket purchases to the map which tracks when they expire.
+	liveHeight := height + int32(s.params.TicketMaturity) + 1
+	expireHeight := liveHeight + int32(s.params.TicketExpiry) - 1
+	s.expireHeights[expireHeight] = purchases
+}
+
+// lotteryWinners returns the winning tickets of the lottery for the block at the
+// provided height, which must be at or after stake validation height, from the
+// current live ticket pool.  A SimError is returned when they can not be
+// selected.
+func (s *Simulator) lotteryWinners(nextHeight int32) ([]*StakeTicket, error) {
+	ticketsPerBlock := s.params.TicketsPerBlock
+	winners, err := winningTickets(s.tip, s.liveTickets, ticketsPerBlock)
+	if err != nil {
+		str := fmt.Sprintf("Unable to select winning tickets at "+
+			"height %d: %v", nextHeight, err)
+		return nil, newSimError(ErrLottery, nextHeight,
+			float64(ticketsPerBlock), float64(s.liveTickets.Len()),
+			str)
+	}
+	return winners, nil
+}
+
+// SimData houses information used to drive the simulation.
+//
+// The fields that are marked optional will be automatically generated if not
+// provided.  They are primarily useful since they allow the simulation to use
+// live data from mainnet to create a exact replication of its ticket pool.
+type SimData struct {
+	Header       []byte // Optional
+	Voters       uint16
+	PrevValid    bool
+	NewTickets   uint8
+	TicketHashes []chainhash.Hash // Optional
+	Revocations  uint16
+
+	// MissedTickets identifies which of the winning tickets miss their
+	// votes.  It is optional and the winning tickets after the first voters
+	// miss when it is not provided.
+	MissedTickets map[chainhash.Hash]struct{}
+}
+
+// NextNode generates a node the builds from the current simulator tip using the
+// passed data to obtain the specific details such as the number of new tickets
+// to purchase, how many tickets to revoke, and the number of voters and makes
+// it the new tip.
+//
+// It also includes sanity checking on the input data and performs various
+// bookkeeping such as tracking the live ticket pool, winning tickets, subsidy
+// generation per number of voters in the input data, and total coin supply.
+// A SimError is returned when the input data is invalid, in which case the
+// simulator state is not modified.
+func (s *Simulator) NextNode(data *SimData) (*BlockNode, error) {
+	var nextHeight int32
+	var totalSupply, spendableSupply, stakedCoins dcrutil.Amount
+	if s.tip != nil {
+		nextHeight = s.tip.height + 1
+		totalSupply = s.tip.totalSupply
+		spendableSupply = s.tip.spendableSupply
+		stakedCoins = s.tip.stakedCoins
+	}
+
+	// Shorter versions of some parameters for convenience.
+	ticketsPerBlock := s.params.TicketsPerBlock
+	stakeValidationHeight := s.params.StakeValidationHeight
+	coinbaseMaturity := int32(s.params.CoinbaseMaturity)
+	ticketMaturity := int32(s.params.TicketMaturity)
+
+	// Perform a bit of sanity checking on the simulation input data.
+	if data.NewTickets > s.params.MaxFreshStakePerBlock {
+		str := fmt.Sprintf("Simulation data attempted to purchase "+
+			"%d new tickets at height %d which is greater than "+
+			"max allowed per block %d", data.NewTickets, nextHeight,
+			s.params.MaxFreshStakePerBlock)
+		return nil, newSimError(ErrTooManyNewTickets, nextHeight,
+			float64(data.NewTickets),
+			float64(s.params.MaxFreshStakePerBlock), str)
+	}
+	if data.Voters > ticketsPerBlock {
+		str := fmt.Sprintf("Simulation data attempted to include %d "+
+			"votes at height %d which is greater than max allowed "+
+			"per block %d", data.Voters, nextHeight,
+			ticketsPerBlock)
+		return nil, newSimError(ErrTooManyVotes, nextHeight,
+			float64(data.Voters), float64(ticketsPerBlock), str)
+	}
+	if int(data.Revocations) > len(s.unrevokedTickets) {
+		str := fmt.Sprintf("Simulation data attempted to revoke %d "+
+			"tickets at height %d which is greater than unrevoked "+
+			"tickets %d", data.Revocations, nextHeight,
+			len(s.unrevokedTickets))
+		return nil, newSimError(ErrTooManyRevocations, nextHeight,
+			float64(data.Revocations),
+			float64(len(s.unrevokedTickets)), str)
+	}
+	if int64(nextHeight) >= stakeValidationHeight &&
+		data.Voters < (ticketsPerBlock/2+1) {
+		str := fmt.Sprintf("Simulation data attempted to include %d "+
+			"votes at height %d which is less than min allowed "+
+			"per block %d", data.Voters, nextHeight,
+			(ticketsPerBlock/2 + 1))
+		return nil, newSimError(ErrTooFewVotes, nextHeight,
+			float64(data.Voters), float64(ticketsPerBlock/2+1), str)
+	}
+	if nextHeight <= int32(s.params.CoinbaseMaturity) {
+		if data.NewTickets != 0 {
+			str := fmt.Sprintf("Simulation data attempted to "+
+				"purchase %d new tickets at height %d before "+
+				"any coins are spendable", data.NewTickets,
+				nextHeight)
+			return nil, newSimError(ErrEarlyPurchase, nextHeight,
+				float64(data.NewTickets), 0, str)
+		}
+	} else if int64(nextHeight) < stakeValidationHeight {
+		if data.Voters != 0 {
+			str := fmt.Sprintf("Simulation data attempted to "+
+				"vote with %d tickets at height %d before "+
+				"stake validation height %d", data.Voters,
+				nextHeight, stakeValidationHeight)
+			return nil, newSimError(ErrEarlyVote, nextHeight,
+				float64(data.Voters), 0, str)
+		}
+		if data.Revocations != 0 {
+			str := fmt.Sprintf("Simulation data attempted to "+
+				"revoke %d tickets at height %d before stake "+
+				"validation height %d", data.Revocations,
+				nextHeight, stakeValidationHeight)
+			return nil, newSimError(ErrEarlyRevocation, nextHeight,
+				float64(data.Revocations), 0, str)
+		}
+	}
+
+	// Generate votes once the stake validation height has been reached.
+	var ticketsWon, ticketsVoted, ticketsMissed []*StakeTicket
+	if int64(nextHeight) >= stakeValidationHeight {
+		winners, err := s.lotteryWinners(nextHeight)
+		if err != nil {
+			return nil, err
+		}
+
+		s.lookupVotingServices(winners)
+		ticketsWon = winners
+		ticketsVoted = winners[:data.Voters]
+		ticketsMissed = winners[data.Voters:]
+		if data.MissedTickets != nil {
+			ticketsVoted, ticketsMissed = nil, nil
+			for _, ticket := range winners {
+				_, missed := data.MissedTickets[ticket.hash]
+				if missed {
+					ticketsMissed = append(ticketsMissed, ticket)
+				} else {
+					ticketsVoted = append(ticketsVoted, ticket)
+				}
+			}
+			if len(ticketsVoted) != int(data.Voters) {
+				str := fmt.Sprintf("Simulation data attempted "+
+					"to include %d votes at height %d while "+
+					"%d of the winning tickets did not miss",
+					data.Voters, nextHeight,
+					len(ticketsVoted))
+				return nil, newSimError(ErrMissedVotesMismatch,
+					nextHeight, float64(data.Voters),
+					float64(len(ticketsVoted)), str)
+			}
+		}
+	}
+
+	// Automatically revoke the missed tickets and the tickets that expire
+	// in this block once automatic revocations are active.
+	var ticketsAutoRevoked []*StakeTicket
+	if s.isAutoRevocationsActive(nextHeight) {
+		ticketsAutoRevoked = s.autoRevokedTickets(nextHeight,
+			ticketsMissed)
+	}
+
+	// Reduce the number of staked coins by all that are becoming unlocked
+	// from the tickets that have voted.
+	for _, ticket := range ticketsVoted {
+		stakedCoins -= ticket.price
+	}
+
+	// Update the current spendable coins supply to include the coins that
+	// will mature in the new block.
+	spendableSupply += s.maturingSupply[nextHeight]
+	delete(s.maturingSupply, nextHeight)
+
+	// Generate mock stake tickets for each new one purchased in the block
+	// and deduct the amount from the spendable supply since the coins will
+	// be locked.
+	ticketPrice := s.nextTicketPrice()
+	var ticketsAdded []*StakeTicket
+	for i := uint8(0); i < data.NewTickets; i++ {
+		// Don't purchase any more tickets if there aren't enough
+		// spendable coins to actually purchase them.
+		if spendableSupply < dcrutil.Amount(ticketPrice) {
+			break
+		}
+
+		// Either use the ticket hash provided by the simulation data or
+		// generate a mock hash when none are provided.
+		var ticketHash chainhash.Hash
+		if data.TicketHashes != nil {
+			ticketHash = data.TicketHashes[i]
+		} else {
+			ticketHash = stakeTicketHash(nextHeight, i)
+		}
+		ticket := newStakeTicket(&ticketHash, nextHeight, ticketPrice)
+		if len(s.vsps) > 0 {
+			ticket.vsp = s.chooseVotingService()
+			if ticket.vsp != nil {
+				s.ticketVSPs[ticketHash] = ticket.vsp
+			}
+		}
+		ticketsAdded = append(ticketsAdded, ticket)
+		spendableSupply -= dcrutil.Amount(ticketPrice)
+		stakedCoins += dcrutil.Amount(ticketPrice)
+	}
+
+	// Choose the simulated number of revocations from the pool of eligible
+	// revocations.  Tickets the revocation model revokes by this height are
+	// chosen first followed by the oldest remaining unrevoked tickets.
+	var ticketsRevoked, remaining []*StakeTicket
+	for _, ticket := range s.unrevokedTickets {
+		if len(ticketsRevoked) < int(data.Revocations) &&
+			ticket.revokeHeight <= nextHeight {
+
+			ticketsRevoked = append(ticketsRevoked, ticket)
+			continue
+		}
+		remaining = append(remaining, ticket)
+	}
+	for len(ticketsRevoked) < int(data.Revocations) {
+		ticketsRevoked = append(ticketsRevoked, remaining[0])
+		remaining = remaining[1:]
+	}
+	s.unrevokedTickets = remaining
+	for _, ticket := range ticketsAutoRevoked {
+		ticket.revocableHeight = nextHeight
+		ticket.revokeHeight = nextHeight
+		ticketsRevoked = append(ticketsRevoked, ticket)
+	}
+	for _, ticket := range ticketsRevoked {
+		stakedCoins -= ticket.price
+	}
+
+	// Create a new fake block based on the provided simulation data and
+	// ticket information generated above.
+	node := newBlockNode(s.tip, ticketsAdded, ticketsVoted, ticketsRevoked)
+	node.header = data.Header
+	if node.header == nil {
+		// Generate fake header bytes based on the height and seed when
+		// it wasn't provided by the simulation data.  The seed is only
+		// included when it is set so the headers remain the same as
+		// older versions of the simulator by default.
+		buf := make([]byte, 4, 12)
+		binary.LittleEndian.PutUint32(buf, uint32(nextHeight))
+		if s.seed != 0 {
+			var seedBytes [8]byte
+			binary.LittleEndian.PutUint64(seedBytes[:], uint64(s.seed))
+			buf = append(buf, seedBytes[:]...)
+		}
+		node.header = buf
+	}
+	node.numVoters = data.Voters
+	node.ticketPrice = ticketPrice
+	node.poolSize = uint32(s.liveTickets.Len())
+	node.numImmature = uint32(len(s.immatureTickets))
+	node.spendableSupply = spendableSupply
+	node.stakedCoins = stakedCoins
+
+	if s.log != nil {
+		fmt.Fprintf(s.log, "nextHeight %v, poolsize %v, immature %v, total %v, "+
+			"spendable %v, bought %v @ %v\n", nextHeight,
+			node.poolSize, node.numImmature,
+			node.poolSize+node.numImmature,
+			spendableSupply, len(ticketsAdded),
+			dcrutil.Amount(ticketPrice))
+	}
+
+	// Calculate the total new supply generated by this block and keep a
+	// running tally of the total supply.  Also, keep track of when the
+	// newly generated coins will mature.
+	var parentRegularSubsidy dcrutil.Amount
+	if node.parent != nil {
+		parentRegularSubsidy = node.parent.regularSubsidy
+	}
+	if nextHeight == 1 {
+		node.regularSubsidy = dcrutil.Amount(s.params.BlockOneSubsidy())
+		s.maturingSupply[nextHeight+coinbaseMaturity] = node.regularSubsidy
+	} else if nextHeight > 0 {
+		// Calculate subsidies for the new block.
+		fullSubsidy := s.CalcFullSubsidy(nextHeight)
+		devSubsidy := s.CalcDevSubsidy(fullSubsidy, nextHeight, data.Voters)
+		powSubsidy := s.CalcPoWSubsidy(fullSubsidy, nextHeight, data.Voters)
+		posSubsidy := s.CalcPoSSubsidy(nextHeight - 1)
+		perVoteSubsidy := posSubsidy / dcrutil.Amount(ticketsPerBlock)
+		voteSubsidy := perVoteSubsidy * dcrutil.Amount(data.Voters)
+
+		// The current model is to only add the proof-of-work and dev
+		// subsidy generated by the previous block to the total supply
+		// if it wasn't invalidated.  This means the reported total
+		// supply is always one block behind what is actually available.
+		if !data.PrevValid {
+			parentRegularSubsidy = 0
+		}
+		newSupply := parentRegularSubsidy + voteSubsidy
+
+		node.regularSubsidy = powSubsidy + devSubsidy
+		node.totalSupply = totalSupply + newSupply
+
+		// Account for maturity of the newly generated coins from PoW,
+		// PoS, and revocations.
+		maturedHeight := nextHeight + coinbaseMaturity
+		s.maturingSupply[maturedHeight] += node.regularSubsidy
+		ticketMaturedHeight := nextHeight + ticketMaturity
+		s.maturingSupply[ticketMaturedHeight] += voteSubsidy
+		for _, ticket := range ticketsVoted {
+			s.maturingSupply[ticketMaturedHeight] += ticket.price
+		}
+		for _, ticket := range ticketsRevoked {
+			s.maturingSupply[ticketMaturedHeight] += ticket.price
+		}
+	}
+
+	// Update the live ticket pool by adding the newly purchased tickets,
+	// removing the winning tickets, removing any tickets that are now
+	// expired, and update related state.  Also, add missed tickets to the
+	// missed and unrevoked tickets pools.
+	s.missedTickets = append(s.missedTickets, ticketsMissed...)
+	if !s.isAutoRevocationsActive(nextHeight) {
+		for _, ticket := range ticketsMissed {
+			s.addUnrevokedTicket(ticket, nextHeight)
+		}
+	}
+	s.connectLiveTickets(nextHeight, ticketsWon, ticketsAdded)
+	s.tip = node
+	if s.root == nil {
+		s.root = node
+	}
+
+	// Notify the ticket price algorithm about the new block and whether or
+	// not it starts a new stake difficulty window when it keeps state.
+	if alg, ok := s.stakeDiffAlgorithm.(StatefulStakeDiffAlgorithm); ok {
+		chain := chainView{s}
+		alg.BlockConnected(chain)
+		if int64(nextHeight)%s.params.StakeDiffWindowSize == 0 {
+			alg.Retarget(chain)
+		}
+	}
+	return node, nil
+}
+
+// newSimulator returns an instance of a type that can be used to perform
+// proof-of-stake simulations.
+func newSimulator(params *chaincfg.Params) *Simulator {
+	rngSource := newCountingSource(0)
+	return &Simulator{
+		params:                params,
+		stakeCap:              0.4,
+		autoRevocationsHeight: -1,
+		rng:                   rand.New(rngSource),
+		rngSource:             rngSource,
+		liveTickets:           tickettreap.NewImmutable(),
+		expireHeights:         make(map[int32][]*StakeTicket),
+		maturingSupply:        make(map[int32]dcrutil.Amount),
+	}
+}
+
+// Config houses the configuration of a Simulator created with New.
+//
+// The ticket price function, demand distribution function, purchase profile,
+// and models default to the same ones as the dcrstakesim command when they are
+// nil, and any model parameters that are nil use the defaults of the model.
+type Config struct {
+	// Params are the network params of the simulated chain.
+	Params *chaincfg.Params
+
+	// Seed varies the simulation between runs that are otherwise
+	// identical.
+	Seed int64
+
+	// StakeCap is the maximum fraction of the total supply that is staked.
+	// It defaults to 0.4 when zero.
+	StakeCap float64
+
+	// AutoRevocations enables automatically revoking missed and expired
+	// tickets in the same block per DCP0009 starting at the height given
+	// by AutoRevocationsHeight, which is otherwise ignored.
+	AutoRevocations       bool
+	AutoRevocationsHeight int32
+
+	// Events change the ticket purchasing behavior during the simulation.
+	Events []Event
+
+	// These fields select the functions and models the simulation uses
+	// along with their parameters.
+	PriceFunc        *PriceFunc
+	DemandFunc       *DemandFunc
+	DemandParams     ModelParams
+	PurchaseProfile  *PurchaseProfile
+	ProfileParams    ModelParams
+	NoiseModel       *NoiseModel
+	NoiseParams      ModelParams
+	MissModel        *MissModel
+	MissParams       ModelParams
+	RevocationModel  *RevocationModel
+	RevocationParams ModelParams
+
+	// Mempool enables the simulated ticket mempool.
+	Mempool       bool
+	MempoolParams ModelParams
+
+	// NumAgents is the number of stakeholder agents that make the ticket
+	// purchase decisions instead of the demand distribution function.  The
+	// agents are disabled when it is zero.  SplitTickets additionally
+	// allows the agents that cannot afford a ticket to purchase split
+	// tickets.
+	NumAgents    int
+	AgentParams  ModelParams
+	SplitTickets bool
+	SplitParams  ModelParams
+
+	// VSPs are the voting service providers tickets are delegated to.
+	VSPs []*VotingService
+
+	// Verify enables verifying the simulated blocks against the block
+	// headers of the CSV input data.
+	Verify bool
+
+	// Progress is where the progress of the simulation is reported.  It
+	// is not reported when nil.
+	Progress io.Writer
+
+	// Log is where additional details about the simulation are written.
+	// They are not written when nil.
+	Log io.Writer
+}
+
+// New returns a simulator configured according to the passed config.  An error
+// is returned when the config does not specify the network params, specifies
+// parameters a model does not accept, or specifies parameter values outside of
+// the range a model supports.
+func New(cfg *Config) (*Simulator, error) {
+	if cfg.Params == nil {
+		return nil, fmt.Errorf("no network params specified")
+	}
+	if !(cfg.StakeCap >= 0 && cfg.StakeCap <= 1) {
+		return nil, fmt.Errorf("stake cap %v is not in the range "+
+			"[0, 1], where 0 selects the default", cfg.StakeCap)
+	}
+
+	pf, df := cfg.PriceFunc, cfg.DemandFunc
+	if pf == nil {
+		pf = LookupPriceFunc("current")
+	}
+	if df == nil {
+		df = LookupDemandFunc("a")
+	}
+	pp, nm := cfg.PurchaseProfile, cfg.NoiseModel
+	if pp == nil {
+		pp = LookupPurchaseProfile("even")
+	}
+	if nm == nil {
+		nm = LookupNoiseModel("none")
+	}
+	mm, rm := cfg.MissModel, cfg.RevocationModel
+	if mm == nil {
+		mm = LookupMissModel("none")
+	}
+	if rm == nil {
+		rm = LookupRevocationModel("immediate")
+	}
+	dfParams, err := df.checkedParamValues(cfg.DemandParams)
+	if err != nil {
+		return nil, err
+	}
+	ppParams, err := pp.checkedParamValues(cfg.ProfileParams)
+	if err != nil {
+		return nil, err
+	}
+	nmParams, err := nm.checkedParamValues(cfg.NoiseParams)
+	if err != nil {
+		return nil, err
+	}
+	mmParams, err := mm.checkedParamValues(cfg.MissParams)
+	if err != nil {
+		return nil, err
+	}
+	rmParams, err := rm.checkedParamValues(cfg.RevocationParams)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newSimulator(cfg.Params)
+	s.progress = cfg.Progress
+	s.log = cfg.Log
+	s.seed = cfg.Seed
+	s.rngSource.Seed(cfg.Seed)
+	if cfg.StakeCap != 0 {
+		s.stakeCap = cfg.StakeCap
+	}
+	if cfg.AutoRevocations {
+		s.autoRevocationsHeight = cfg.AutoRevocationsHeight
+	}
+	s.events = cfg.Events
+	if cfg.Mempool {
+		params, err := ModelParamValues("ticket mempool",
+			MempoolParams, cfg.MempoolParams, ValidateMempoolParams)
+		if err != nil {
+			return nil, err
+		}
+		s.mempool = newTicketMempool(params)
+	}
+	if cfg.NumAgents > 0 {
+		params, err := ModelParamValues("stakeholder agent",
+			AgentParams, cfg.AgentParams, ValidateAgentParams)
+		if err != nil {
+			return nil, err
+		}
+		s.agents = newAgentPopulation(s.rng, cfg.NumAgents, params)
+		if cfg.SplitTickets {
+			params, err := ModelParamValues("split ticket",
+				SplitParams, cfg.SplitParams,
+				ValidateSplitParams)
+			if err != nil {
+				return nil, err
+			}
+			s.agents.splitParams = params
+		}
+	}
+	if cfg.Verify {
+		s.verify = new(VerifySummary)
+	}
+	if len(cfg.VSPs) > 0 {
+		s.vsps = cfg.VSPs
+		s.ticketVSPs = make(map[chainhash.Hash]*VotingService)
+	}
+	s.SetStakeDiffAlgorithm(pf.NewAlgorithm())
+	s.SetDemandFunc(func(s *Simulator, nextHeight int32, ticketPrice int64) float64 {
+		return df.Calc(s, dfParams, nextHeight, ticketPrice)
+	})
+	s.purchaseProfileFunc = func(demandPerWindow, pos int32) int32 {
+		return pp.Calc(s, ppParams, demandPerWindow, pos)
+	}
+	s.noiseFunc = func(demand float64) float64 {
+		return nm.Apply(s, nmParams, demand)
+	}
+	s.missFunc = func(nextHeight int32, numWinners uint16) uint16 {
+		return mm.Calc(s, mmParams, nextHeight, numWinners)
+	}
+	s.revokeFunc = func(ticket *StakeTicket, height int32) int32 {
+		return rm.Calc(s, rmParams, ticket, height)
+	}
+	return s, nil
+}
+
+// SetStakeDiffAlgorithm replaces the algorithm the simulator uses to calculate
+// the ticket price of the next block.  This allows ticket price algorithms that
+// are not registered to be simulated.  Algorithms that implement the
+// StatefulStakeDiffAlgorithm interface are notified about each block connected
+// after they are set.
+func (s *Simulator) SetStakeDiffAlgorithm(alg StakeDiffAlgorithm) {
+	s.stakeDiffAlgorithm = alg
+}
+
+// nextTicketPrice returns the ticket price for the block after the current tip
+// as calculated by the ticket price algorithm of the simulator.
+func (s *Simulator) nextTicketPrice() int64 {
+	return s.stakeDiffAlgorithm.NextStakeDiff(chainView{s})
+}
+
+// SetDemandFunc replaces the function the simulator uses to calculate the
+// demand for tickets in each stake difficulty window given the next height and
+// the ticket price of the next block.  The demand must be in the range [0, 1].
+func (s *Simulator) SetDemandFunc(calc func(s *Simulator, nextHeight int32, ticketPrice int64) float64) {
+	s.demandFunc = func(nextHeight int32, ticketPrice int64) float64 {
+		return calc(s, nextHeight, ticketPrice)
+	}
+}
+
+// SetProgress sets where the progress of the simulation is reported.  Passing
+// nil disables reporting the progress.
+func (s *Simulator) SetProgress(w io.Writer) {
+	s.progress = w
+}
+
+// Params returns the network params of the simulated chain.
+func (s *Simulator) Params() *chaincfg.Params {
+	return s.params
+}
+
+// Seed returns the seed used to vary the simulation.
+func (s *Simulator) Seed() int64 {
+	return s.seed
+}
+
+// Root returns the first block of the simulated chain or nil when no blocks
+// have been simulated.
+func (s *Simulator) Root() *BlockNode {
+	return s.root
+}
+
+// Tip returns the most recent block of the simulated chain or nil when no
+// blocks have been simulated.
+func (s *Simulator) Tip() *BlockNode {
+	return s.tip
+}
+
+// ScheduledEvents returns the configured events with their heights resolved
+// for the number of blocks being simulated.
+func (s *Simulator) ScheduledEvents() []*ScheduledEvent {
+	return s.scheduledEvents
+}
+
+// Verify returns the results of verifying the simulated blocks against the
+// block headers of the CSV input data or nil when verification is disabled.
+func (s *Simulator) Verify() *VerifySummary {
+	return s.verify
+}
+
+// ImmatureTickets returns the purchased tickets that have not yet matured.
+func (s *Simulator) ImmatureTickets() []*StakeTicket {
+	return s.immatureTickets
+}
+
+// LiveTickets returns the tickets in the live ticket pool ordered by their
+// hash.  Only their purchase details are available.
+func (s *Simulator) LiveTickets() []*StakeTicket {
+	tickets := make([]*StakeTicket, 0, s.liveTickets.Len())
+	s.liveTickets.ForEach(func(key tickettreap.Key, val *tickettreap.Value) bool {
+		ticketHash := (*chainhash.Hash)(&key)
+		tickets = append(tickets, newStakeTicket(ticketHash,
+			val.PurchaseHeight, val.PurchasePrice))
+		return true
+	})
+	return tickets
+}
+
+// PoolSize returns the number of tickets in the live ticket pool.
+func (s *Simulator) PoolSize() int {
+	return s.liveTickets.Len()
+}
+
+// WonTickets returns the tickets that have won the lottery.
+func (s *Simulator) WonTickets() []*StakeTicket {
+	return s.wonTickets
+}
+
+// MissedTickets returns the tickets that won the lottery but missed their
+// votes.
+func (s *Simulator) MissedTickets() []*StakeTicket {
+	return s.missedTickets
+}
+
+// ExpiredTickets returns the tickets that expired without winning the lottery.
+func (s *Simulator) ExpiredTickets() []*StakeTicket {
+	return s.expiredTickets
+}
+
+// UnrevokedTickets returns the missed and expired tickets that have not been
+// revoked yet.
+func (s *Simulator) UnrevokedTickets() []*StakeTicket {
+	return s.unrevokedTickets
+}
+
+// WinningTickets returns the tickets selected by the lottery to vote on the
+// current tip, which are the winning tickets of the next block.
+func (s *Simulator) WinningTickets() ([]*StakeTicket, error) {
+	var nextHeight int32
+	if s.tip != nil {
+		nextHeight = s.tip.height + 1
+	}
+	return s.lotteryWinners(nextHeight)
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"math"
+	"testing"
+
+	"github.com/decred/dcrd/chaincfg"
+)
+
+// TestNewStakeCap ensures New accepts stake caps in the range [0, 1], where 0
+// selects the default stake cap, and rejects all others.
+func TestNewStakeCap(t *testing.T) {
+	tests := []struct {
+		name     string
+		stakeCap float64
+		want     float64
+		valid    bool
+	}{
+		{name: "zero selects default", stakeCap: 0, want: 0.4, valid: true},
+		{name: "smallest", stakeCap: 0.01, want: 0.01, valid: true},
+		{name: "largest", stakeCap: 1, want: 1, valid: true},
+		{name: "negative", stakeCap: -0.1},
+		{name: "over one", stakeCap: 1.1},
+		{name: "NaN", stakeCap: math.NaN()},
+	}
+
+	for _, test := range tests {
+		sim, err := New(&Config{
+			Params:   &chaincfg.MainNetParams,
+			StakeCap: test.stakeCap,
+		})
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s: did not receive expected error",
+					test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if sim.stakeCap != test.want {
+			t.Errorf("%s: got stake cap %v, want %v", test.name,
+				sim.stakeCap, test.want)
+		}
+	}
+}
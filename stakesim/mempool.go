@@ -0,0 +1,139 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// MempoolParams are the tunable parameters of the simulated ticket mempool.
+var MempoolParams = []ModelParam{{
+	Name:         "basefee",
+	Description:  "Median fee rate in DCR/kB of ticket purchases",
+	DefaultValue: 0.001,
+}, {
+	Name:         "feespread",
+	Description:  "Standard deviation of the log of the fee rates of ticket purchases",
+	DefaultValue: 0.5,
+}}
+
+// ValidateMempoolParams returns an error if any of the passed mempool
+// parameters are outside of their valid range.
+func ValidateMempoolParams(params ModelParams) error {
+	switch {
+	case params["basefee"] <= 0:
+		return fmt.Errorf("mempool parameter \"basefee\" must be " +
+			"positive")
+	case params["feespread"] < 0:
+		return fmt.Errorf("mempool parameter \"feespread\" must not " +
+			"be negative")
+	}
+	return nil
+}
+
+// ticketIntent houses a ticket purchase which is waiting in the simulated
+// mempool to be included in a block.
+type ticketIntent struct {
+	feeRate      float64
+	submitHeight int32
+}
+
+// intentsByFee provides sorting of ticket intents by their fee rate with the
+// highest fee rate first.  It is used with a stable sort so intents with the
+// same fee rate remain in the order they were submitted.
+type intentsByFee []ticketIntent
+
+func (s intentsByFee) Len() int           { return len(s) }
+func (s intentsByFee) Less(i, j int) bool { return s[i].feeRate > s[j].feeRate }
+func (s intentsByFee) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// ticketMempool houses ticket purchases which compete for the limited number of
+// new tickets allowed in each block by their fee rate along with statistics
+// about them.
+//
+// Ticket purchases commit to the ticket price of the window they are submitted
+// in, so any that are still waiting when the price changes are expired.
+type ticketMempool struct {
+	params  ModelParams
+	intents []ticketIntent
+	window  int32
+
+	numMined      int
+	numExpired    int
+	waitSum       float64
+	minedFeeSum   float64
+	expiredFeeSum float64
+}
+
+// newTicketMempool returns an empty ticket mempool with the given parameters,
+// which must contain a value for every mempool parameter.
+func newTicketMempool(params ModelParams) *ticketMempool {
+	return &ticketMempool{params: params}
+}
+
+// clone returns a copy of the mempool that is not affected by any changes to
+// the original.
+func (mp *ticketMempool) clone() *ticketMempool {
+	clone := *mp
+	clone.intents = append([]ticketIntent(nil), mp.intents...)
+	return &clone
+}
+
+// depth returns the number of ticket purchases waiting in the mempool.
+func (mp *ticketMempool) depth() int {
+	return len(mp.intents)
+}
+
+// mempoolPurchases adds the provided number of ticket purchases made in the
+// block at the provided height to the mempool with randomly distributed fee
+// rates and returns how many of the waiting purchases are included in the
+// block.  The block is filled by fee priority up to the maximum number of new
+// tickets allowed per block or the number of tickets the spendable supply can
+// pay for at the provided ticket price, whichever is less, and purchases from a
+// previous window are expired first.
+func (s *Simulator) mempoolPurchases(nextHeight int32, ticketPrice int64, purchases int32) uint8 {
+	mp := s.mempool
+	window := nextHeight / int32(s.params.StakeDiffWindowSize)
+	if window != mp.window {
+		for _, intent := range mp.intents {
+			mp.expiredFeeSum += intent.feeRate
+		}
+		mp.numExpired += len(mp.intents)
+		mp.intents = mp.intents[:0]
+		mp.window = window
+	}
+
+	baseFee, feeSpread := mp.params["basefee"], mp.params["feespread"]
+	for i := int32(0); i < purchases; i++ {
+		feeRate := baseFee * math.Exp(feeSpread*s.rng.NormFloat64())
+		mp.intents = append(mp.intents, ticketIntent{
+			feeRate:      feeRate,
+			submitHeight: nextHeight,
+		})
+	}
+
+	sort.Stable(intentsByFee(mp.intents))
+	numMined := int64(len(mp.intents))
+	maxNewTickets := int64(s.params.MaxFreshStakePerBlock)
+	if numMined > maxNewTickets {
+		numMined = maxNewTickets
+	}
+	var maxPossible int64
+	if s.tip != nil {
+		maxPossible = int64(s.tip.spendableSupply) / ticketPrice
+	}
+	if numMined > maxPossible {
+		numMined = maxPossible
+	}
+	for _, intent := range mp.intents[:numMined] {
+		mp.waitSum += float64(nextHeight - intent.submitHeight)
+		mp.minedFeeSum += intent.feeRate
+	}
+	mp.numMined += int(numMined)
+	mp.intents = append(mp.intents[:0], mp.intents[numMined:]...)
+	return uint8(numMined)
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import "io"
+
+// MissModel describes a model which determines how many of the tickets that
+// win the lottery in a block fail to vote along with details about its
+// behavior so the simulation results are self describing.
+type MissModel struct {
+	Model
+
+	// Calc returns the number of the provided number of winning tickets
+	// for the block at the provided height that miss their vote.  The
+	// passed parameters contain a value for every parameter the model
+	// defines.
+	Calc func(s *Simulator, params ModelParams, nextHeight int32, numWinners uint16) uint16
+}
+
+// missModels houses all registered miss models in the order they were
+// registered.
+var missModels = newModelRegistry("miss model")
+
+// RegisterMissModel makes the provided miss model available to the simulator
+// under its key.  Much like RegisterDemandFunc, it is intended to be called
+// from the init function of the file that defines the model.
+//
+// This function will panic if the key is empty or reserved, the calculation
+// function is nil, or a miss model with the same key has already been
+// registered since those are programming errors.
+func RegisterMissModel(mm *MissModel) {
+	missModels.register(mm.Key, mm, mm.Calc != nil)
+}
+
+// LookupMissModel returns the registered miss model for the provided key or nil
+// when there is no such model.
+func LookupMissModel(key string) *MissModel {
+	mm, _ := missModels.lookup(key).(*MissModel)
+	return mm
+}
+
+// MissModelKeys returns the keys of all registered miss models in the order
+// they were registered.
+func MissModelKeys() []string {
+	return missModels.registeredKeys()
+}
+
+// PrintMissModels prints all of the registered miss models along with their
+// descriptions and tunable parameters to the passed writer.
+func PrintMissModels(w io.Writer) {
+	missModels.print(w, "Available missed vote models:")
+}
+
+// countMisses returns how many of the provided number of winning tickets miss
+// their vote when each one independently misses with the given probability.
+func (s *Simulator) countMisses(numWinners uint16, missProb float64) uint16 {
+	var misses uint16
+	for i := uint16(0); i < numWinners; i++ {
+		if s.rng.Float64() < missProb {
+			misses++
+		}
+	}
+	return misses
+}
+
+// missModelNone never misses any votes.  This matches the behavior of older
+// versions of the simulator.
+func missModelNone(s *Simulator, params ModelParams, nextHeight int32, numWinners uint16) uint16 {
+	return 0
+}
+
+// missModelFixed misses each vote independently with a fixed probability.
+func missModelFixed(s *Simulator, params ModelParams, nextHeight int32, numWinners uint16) uint16 {
+	return s.countMisses(numWinners, params["missrate"])
+}
+
+// missModelOutage misses each vote independently with a fixed probability
+// except during randomly occurring outages, such as a large voting service
+// going offline, where a much larger portion of the votes are missed for a
+// number of blocks.
+func missModelOutage(s *Simulator, params ModelParams, nextHeight int32, numWinners uint16) uint16 {
+	if nextHeight > s.outageEndHeight &&
+		s.rng.Float64() < params["outagerate"] {
+
+		s.outageEndHeight = nextHeight + int32(params["outagelength"]) - 1
+	}
+	missProb := params["missrate"]
+	if nextHeight <= s.outageEndHeight {
+		missProb = params["outagemissrate"]
+	}
+	return s.countMisses(numWinners, missProb)
+}
+
+// missModelPopulation models the voters as two populations with different
+// availability.  Solo voters run their own wallets and are more likely to be
+// offline than voters that delegate to always-online voting services.
+func missModelPopulation(s *Simulator, params ModelParams, nextHeight int32, numWinners uint16) uint16 {
+	var misses uint16
+	for i := uint16(0); i < numWinners; i++ {
+		availability := params["vspavailability"]
+		if s.rng.Float64() < params["soloshare"] {
+			availability = params["soloavailability"]
+		}
+		if s.rng.Float64() >= availability {
+			misses++
+		}
+	}
+	return misses
+}
+
+func init() {
+	RegisterMissModel(&MissModel{
+		Model: Model{
+			Key:         "none",
+			Description: "Every winning ticket votes",
+		},
+		Calc: missModelNone,
+	})
+	RegisterMissModel(&MissModel{
+		Model: Model{
+			Key:         "fixed",
+			Description: "Each winning ticket misses with a fixed probability",
+			Params: []ModelParam{{
+				Name:         "missrate",
+				Description:  "Probability a winning ticket misses its vote",
+				DefaultValue: 0.01,
+			}},
+			Validate: func(params ModelParams) error {
+				return validateFractions(params, "missrate")
+			},
+		},
+		Calc: missModelFixed,
+	})
+	RegisterMissModel(&MissModel{
+		Model: Model{
+			Key:         "outage",
+			Description: "Fixed miss probability with random bursts of outages",
+			Params: []ModelParam{{
+				Name:         "missrate",
+				Description:  "Probability a winning ticket misses its vote outside of outages",
+				DefaultValue: 0.01,
+			}, {
+				Name:         "outagerate",
+				Description:  "Probability an outage starts at each block",
+				DefaultValue: 0.001,
+			}, {
+				Name:         "outagelength",
+				Description:  "Number of blocks an outage lasts",
+				DefaultValue: 12,
+			}, {
+				Name:         "outagemissrate",
+				Description:  "Probability a winning ticket misses its vote during an outage",
+				DefaultValue: 0.4,
+			}},
+			Validate: func(params ModelParams) error {
+				err := validateFractions(params, "missrate",
+					"outagerate", "outagemissrate")
+				if err != nil {
+					return err
+				}
+				return validateAtLeast(params, 1, "outagelength")
+			},
+		},
+		Calc: missModelOutage,
+	})
+	RegisterMissModel(&MissModel{
+		Model: Model{
+			Key:         "population",
+			Description: "Solo voters and voting service users with different availability",
+			Params: []ModelParam{{
+				Name:         "soloshare",
+				Description:  "Fraction of tickets owned by solo voters",
+				DefaultValue: 0.3,
+			}, {
+				Name:         "soloavailability",
+				Description:  "Probability a solo voter is online to vote",
+				DefaultValue: 0.95,
+			}, {
+				Name:         "vspavailability",
+				Description:  "Probability a voting service is online to vote",
+				DefaultValue: 0.995,
+			}},
+			Validate: func(params ModelParams) error {
+				return validateFractions(params, "soloshare",
+					"soloavailability", "vspavailability")
+			},
+		},
+		Calc: missModelPopulation,
+	})
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ModelParam describes a tunable parameter of a model used by the simulator
+// such as a demand distribution function.
+type ModelParam struct {
+	Name         string
+	Description  string
+	DefaultValue float64
+}
+
+// ModelParams houses the values of the tunable parameters for a model keyed by
+// their name.
+type ModelParams map[string]float64
+
+// String returns the parameters as a comma-separated list of name=value pairs
+// sorted by name.  This is the same format accepted by ParseModelParams.
+func (p ModelParams) String() string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		value := strconv.FormatFloat(p[name], 'g', -1, 64)
+		pairs = append(pairs, name+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// ParseModelParams parses the passed string, which must be a comma-separated
+// list of name=value pairs, into model parameters.  An error is returned if any
+// of the values are not valid numbers.
+func ParseModelParams(str string) (ModelParams, error) {
+	params := make(ModelParams)
+	if str == "" {
+		return params, nil
+	}
+	for _, pair := range strings.Split(str, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("parameter %q is not in the form "+
+				"name=value", pair)
+		}
+		name := strings.TrimSpace(parts[0])
+		value, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %q has an invalid "+
+				"value: %v", name, err)
+		}
+		params[name] = value
+	}
+	return params, nil
+}
+
+// SplitList splits the passed comma-separated list into its individual entries
+// with any surrounding whitespace removed.
+func SplitList(list string) []string {
+	entries := strings.Split(list, ",")
+	for i, entry := range entries {
+		entries[i] = strings.TrimSpace(entry)
+	}
+	return entries
+}
+
+// defaultModelParams returns the default values for all of the passed tunable
+// parameters.
+func defaultModelParams(defs []ModelParam) ModelParams {
+	params := make(ModelParams, len(defs))
+	for _, def := range defs {
+		params[def.Name] = def.DefaultValue
+	}
+	return params
+}
+
+// WithModelParamOverrides returns the default values for the passed tunable
+// parameters with any of the overrides that are one of the parameters applied.
+// Overrides for other parameters are ignored.
+func WithModelParamOverrides(defs []ModelParam, overrides ModelParams) ModelParams {
+	params := defaultModelParams(defs)
+	for name, value := range overrides {
+		if _, ok := params[name]; ok {
+			params[name] = value
+		}
+	}
+	return params
+}
+
+// AcceptsModelParam returns whether or not the passed tunable parameters
+// include one with the provided name.
+func AcceptsModelParam(defs []ModelParam, name string) bool {
+	for _, def := range defs {
+		if def.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ModelParamValues returns the values for the passed tunable parameters of a
+// simulator feature, such as the ticket mempool, with the provided overrides
+// applied to the defaults.  An error is returned when any of the overrides are
+// not one of the parameters or the validate function, which may be nil, rejects
+// the resulting values.  The passed name describes the feature in errors.
+func ModelParamValues(name string, defs []ModelParam, overrides ModelParams, validate func(ModelParams) error) (ModelParams, error) {
+	for param := range overrides {
+		if !AcceptsModelParam(defs, param) {
+			return nil, fmt.Errorf("unknown %s parameter %q", name,
+				param)
+		}
+	}
+	params := WithModelParamOverrides(defs, overrides)
+	if validate != nil {
+		if err := validate(params); err != nil {
+			return nil, err
+		}
+	}
+	return params, nil
+}
+
+// PrintModelParams prints the passed tunable parameters along with their
+// default values and descriptions to the passed writer.
+func PrintModelParams(w io.Writer, defs []ModelParam) {
+	for _, def := range defs {
+		fmt.Fprintf(w, "      %s (default %v): %s\n", def.Name,
+			def.DefaultValue, def.Description)
+	}
+}
+
+// validateFractions returns an error if any of the named parameters, such as
+// probabilities, are not in the range [0, 1].
+func validateFractions(params ModelParams, names ...string) error {
+	for _, name := range names {
+		if v := params[name]; !(v >= 0 && v <= 1) {
+			return fmt.Errorf("%s %v is not in the range [0, 1]",
+				name, v)
+		}
+	}
+	return nil
+}
+
+// validateAtLeast returns an error if any of the named parameters are less
+// than the provided minimum value.
+func validateAtLeast(params ModelParams, min float64, names ...string) error {
+	for _, name := range names {
+		if v := params[name]; !(v >= min) {
+			return fmt.Errorf("%s %v must be at least %v", name, v,
+				min)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"io"
+	"math"
+)
+
+// NoiseModel describes a model which perturbs the demand produced by any demand
+// distribution function with random noise drawn from the seeded random source
+// of the simulator along with details about its behavior so the simulation
+// results are self describing.
+type NoiseModel struct {
+	Model
+
+	// Calc returns the provided demand with noise applied.  The result is
+	// limited to the range [0, 1] by the caller.  The passed parameters
+	// contain a value for every parameter the model defines.
+	Calc func(s *Simulator, params ModelParams, demand float64) float64
+}
+
+// Apply returns the provided demand with noise applied and limited to the range
+// [0, 1].
+func (nm *NoiseModel) Apply(s *Simulator, params ModelParams, demand float64) float64 {
+	return math.Max(0, math.Min(1, nm.Calc(s, params, demand)))
+}
+
+// noiseModels houses all registered noise models in the order they were
+// registered.
+var noiseModels = newModelRegistry("noise model")
+
+// RegisterNoiseModel makes the provided noise model available to the simulator
+// under its key.  Much like RegisterDemandFunc, it is intended to be called
+// from the init function of the file that defines the model.
+//
+// This function will panic if the key is empty or reserved, the calculation
+// function is nil, or a noise model with the same key has already been
+// registered since those are programming errors.
+func RegisterNoiseModel(nm *NoiseModel) {
+	noiseModels.register(nm.Key, nm, nm.Calc != nil)
+}
+
+// LookupNoiseModel returns the registered noise model for the provided key or
+// nil when there is no such model.
+func LookupNoiseModel(key string) *NoiseModel {
+	nm, _ := noiseModels.lookup(key).(*NoiseModel)
+	return nm
+}
+
+// NoiseModelKeys returns the keys of all registered noise models in the order
+// they were registered.
+func NoiseModelKeys() []string {
+	return noiseModels.registeredKeys()
+}
+
+// PrintNoiseModels prints all of the registered noise models along with their
+// descriptions and tunable parameters to the passed writer.
+func PrintNoiseModels(w io.Writer) {
+	noiseModels.print(w, "Available demand noise models:")
+}
+
+// noiseModelNone does not apply any noise.
+func noiseModelNone(s *Simulator, params ModelParams, demand float64) float64 {
+	return demand
+}
+
+// noiseModelUniform skews the demand by a uniformly distributed amount up to
+// the skew in either direction.  Demand that is within the skew of either end
+// of the range is only skewed away from that end.
+func noiseModelUniform(s *Simulator, params ModelParams, demand float64) float64 {
+	skew := params["skew"]
+	switch {
+	case demand > 1-skew:
+		return demand - s.rng.Float64()*skew
+	case demand < skew:
+		return demand + s.rng.Float64()*skew
+	}
+	return demand - skew + s.rng.Float64()*(skew+skew)
+}
+
+// noiseModelGaussian perturbs the demand by a normally distributed amount.
+func noiseModelGaussian(s *Simulator, params ModelParams, demand float64) float64 {
+	return demand + s.rng.NormFloat64()*params["stddev"]
+}
+
+// noiseModelMeanReverting perturbs the demand by an amount that follows an
+// Ornstein-Uhlenbeck process so that periods of higher or lower than modeled
+// demand persist for a while before reverting back to the modeled demand.
+func noiseModelMeanReverting(s *Simulator, params ModelParams, demand float64) float64 {
+	s.noiseState += -params["reversion"]*s.noiseState +
+		s.rng.NormFloat64()*params["volatility"]
+	return demand + s.noiseState
+}
+
+func init() {
+	RegisterNoiseModel(&NoiseModel{
+		Model: Model{
+			Key:         "none",
+			Description: "No noise is applied to the demand",
+		},
+		Calc: noiseModelNone,
+	})
+	RegisterNoiseModel(&NoiseModel{
+		Model: Model{
+			Key:         "uniform",
+			Description: "Demand is skewed by a uniformly distributed amount",
+			Params: []ModelParam{{
+				Name:         "skew",
+				Description:  "Maximum amount the demand is skewed in either direction",
+				DefaultValue: 0.25,
+			}},
+			Validate: func(params ModelParams) error {
+				return validateFractions(params, "skew")
+			},
+		},
+		Calc: noiseModelUniform,
+	})
+	RegisterNoiseModel(&NoiseModel{
+		Model: Model{
+			Key:         "gaussian",
+			Description: "Demand is perturbed by a normally distributed amount",
+			Params: []ModelParam{{
+				Name:         "stddev",
+				Description:  "Standard deviation of the perturbation",
+				DefaultValue: 0.1,
+			}},
+			Validate: func(params ModelParams) error {
+				return validateAtLeast(params, 0, "stddev")
+			},
+		},
+		Calc: noiseModelGaussian,
+	})
+	RegisterNoiseModel(&NoiseModel{
+		Model: Model{
+			Key:         "meanreverting",
+			Description: "Demand is perturbed by a mean-reverting random walk",
+			Params: []ModelParam{{
+				Name:         "reversion",
+				Description:  "Fraction of the perturbation that reverts each stake difficulty interval",
+				DefaultValue: 0.2,
+			}, {
+				Name:         "volatility",
+				Description:  "Standard deviation of the change in the perturbation each stake difficulty interval",
+				DefaultValue: 0.05,
+			}},
+			Validate: func(params ModelParams) error {
+				err := validateFractions(params, "reversion")
+				if err != nil {
+					return err
+				}
+				return validateAtLeast(params, 0, "volatility")
+			},
+		},
+		Calc: noiseModelMeanReverting,
+	})
+}
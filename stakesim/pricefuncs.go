@@ -0,0 +1,74 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+// PriceFunc describes a function which calculates the required stake
+// difficulty (aka ticket price) along with details about who proposed it and
+// where so the simulation results are self describing.
+type PriceFunc struct {
+	// Key is the unique identifier used to select the function with the
+	// -pf flag.
+	Key string
+
+	// Name is the human-readable name of the function shown in the
+	// simulation results.
+	Name string
+
+	// Author and URL identify who proposed the algorithm and where the
+	// proposal can be found.  They are both optional.
+	Author string
+	URL    string
+
+	// NewAlgorithm returns the algorithm which calculates the ticket price.
+	// It is called once for each simulator so algorithms that keep state
+	// must return a new instance every time.
+	NewAlgorithm func() StakeDiffAlgorithm
+}
+
+// Description returns a human-readable description of the price function that
+// includes its name along with its author and reference url when they are
+// available.
+func (pf *PriceFunc) Description() string {
+	desc := pf.Name
+	if pf.Author != "" {
+		desc += " by " + pf.Author
+	}
+	if pf.URL != "" {
+		desc += " (" + pf.URL + ")"
+	}
+	return desc
+}
+
+// priceFuncs houses all registered price functions in the order they
+// were registered.
+var priceFuncs = newModelRegistry("price function")
+
+// RegisterPriceFunc makes the provided ticket price function available to the
+// simulator under its key.  It is intended to be called from the init function
+// of the file that defines the price function so that new proposals can be
+// added in separate files without needing to modify any other code.
+//
+// This function will panic if the key is empty, the algorithm constructor is
+// nil, or a price function with the same key has already been registered since
+// those are programming errors.
+func RegisterPriceFunc(pf *PriceFunc) {
+	priceFuncs.register(pf.Key, pf, pf.NewAlgorithm != nil)
+	if pf.Name == "" {
+		pf.Name = pf.Key
+	}
+}
+
+// LookupPriceFunc returns the registered price function for the provided key
+// or nil when there is no such function.
+func LookupPriceFunc(key string) *PriceFunc {
+	pf, _ := priceFuncs.lookup(key).(*PriceFunc)
+	return pf
+}
+
+// PriceFuncKeys returns the keys of all registered price functions in the order
+// they were registered.
+func PriceFuncKeys() []string {
+	return priceFuncs.registeredKeys()
+}
@@ -2,34 +2,124 @@
 // Use of this source code is governed by an ISC
 // license that can be found in the LICENSE file.
 
-package main
+package stakesim
 
 import (
-	"github.com/davecgh/dcrstakesim/internal/tickettreap"
+	"github.com/decred/dcrd/chaincfg"
 	"github.com/decred/dcrutil"
 	"math"
 )
 
+func init() {
+	const issue584 = "https://github.com/decred/dcrd/issues/584"
+	RegisterPriceFunc(&PriceFunc{
+		Key:          "1",
+		Name:         "Proposal 1",
+		Author:       "raedah",
+		URL:          issue584,
+		NewAlgorithm: statelessAlgorithm(calcNextStakeDiffProposal1),
+	})
+	RegisterPriceFunc(&PriceFunc{
+		Key:          "1E",
+		Name:         "Proposal 1E",
+		Author:       "raedah",
+		URL:          issue584,
+		NewAlgorithm: statelessAlgorithm(calcNextStakeDiffProposal1E),
+	})
+	RegisterPriceFunc(&PriceFunc{
+		Key:          "1F",
+		Name:         "Proposal 1F",
+		Author:       "raedah",
+		URL:          issue584,
+		NewAlgorithm: statelessAlgorithm(calcNextStakeDiffProposal1F),
+	})
+	RegisterPriceFunc(&PriceFunc{
+		Key:          "1G",
+		Name:         "Proposal 1G",
+		Author:       "raedah",
+		URL:          issue584,
+		NewAlgorithm: statelessAlgorithm(calcNextStakeDiffProposal1G),
+	})
+	RegisterPriceFunc(&PriceFunc{
+		Key:          "1H",
+		Name:         "Proposal 1H",
+		Author:       "raedah",
+		URL:          issue584,
+		NewAlgorithm: statelessAlgorithm(calcNextStakeDiffProposal1H),
+	})
+	RegisterPriceFunc(&PriceFunc{
+		Key:          "1R",
+		Name:         "Proposal 1R",
+		Author:       "raedah",
+		URL:          issue584,
+		NewAlgorithm: statelessAlgorithm(calcNextStakeDiffProposal1R),
+	})
+	RegisterPriceFunc(&PriceFunc{
+		Key:          "2",
+		Name:         "Proposal 2",
+		Author:       "animedow",
+		URL:          issue584,
+		NewAlgorithm: statelessAlgorithm(calcNextStakeDiffProposal2),
+	})
+	RegisterPriceFunc(&PriceFunc{
+		Key:          "3",
+		Name:         "Proposal 3",
+		Author:       "coblee",
+		URL:          issue584,
+		NewAlgorithm: statelessAlgorithm(calcNextStakeDiffProposal3),
+	})
+	RegisterPriceFunc(&PriceFunc{
+		Key:          "4",
+		Name:         "Proposal 4",
+		Author:       "jyap808",
+		URL:          issue584,
+		NewAlgorithm: statelessAlgorithm(calcNextStakeDiffProposal4),
+	})
+	RegisterPriceFunc(&PriceFunc{
+		Key:          "5",
+		Name:         "Proposal 5",
+		Author:       "edsonbrusque",
+		URL:          issue584,
+		NewAlgorithm: func() StakeDiffAlgorithm { return new(pidStakeDiff) },
+	})
+	RegisterPriceFunc(&PriceFunc{
+		Key:          "6",
+		Name:         "Proposal 6",
+		Author:       "chappjc",
+		URL:          issue584,
+		NewAlgorithm: statelessAlgorithm(calcNextStakeDiffProposal6),
+	})
+	RegisterPriceFunc(&PriceFunc{
+		Key:          "7",
+		Name:         "Proposal 7",
+		Author:       "raedah, jy-p, and davecgh",
+		URL:          issue584,
+		NewAlgorithm: statelessAlgorithm(calcNextStakeDiffProposal7),
+	})
+}
+
 // calcNextStakeDiffProposal1 returns the required stake difficulty (aka ticket
-// price) for the block after the current tip block the simulator is associated
-// with using the algorithm proposed by raedah in
+// price) for the block after the tip of the passed chain view using the
+// algorithm proposed by raedah in
 // https://github.com/decred/dcrd/issues/584
-func (s *simulator) calcNextStakeDiffProposal1() int64 {
+func calcNextStakeDiffProposal1(chain ChainView) int64 {
+	params, tip := chain.Params(), chain.Tip()
+
 	// Stake difficulty before any tickets could possibly be purchased is
 	// the minimum value.
 	nextHeight := int32(0)
-	if s.tip != nil {
-		nextHeight = s.tip.height + 1
+	if tip != nil {
+		nextHeight = tip.Height + 1
 	}
-	stakeDiffStartHeight := int32(s.params.CoinbaseMaturity) + 1
+	stakeDiffStartHeight := int32(params.CoinbaseMaturity) + 1
 	if nextHeight < stakeDiffStartHeight {
-		return s.params.MinimumStakeDiff
+		return params.MinimumStakeDiff
 	}
 
 	// Return the previous block's difficulty requirements if the next block
 	// is not at a difficulty retarget interval.
-	intervalSize := s.params.StakeDiffWindowSize
-	curDiff := s.tip.ticketPrice
+	intervalSize := params.StakeDiffWindowSize
+	curDiff := tip.TicketPrice
 	if int64(nextHeight)%intervalSize != 0 {
 		return curDiff
 	}
@@ -37,9 +127,9 @@ func (s *simulator) calcNextStakeDiffProposal1() int64 {
 	// Attempt to get the pool size from the previous retarget interval.
 	var prevPoolSize int64
 	prevRetargetHeight := nextHeight - int32(intervalSize)
-	node := s.ancestorNode(s.tip, prevRetargetHeight, nil)
+	node := chain.Ancestor(prevRetargetHeight)
 	if node != nil {
-		prevPoolSize = int64(node.poolSize)
+		prevPoolSize = int64(node.PoolSize)
 	}
 
 	// Return the existing ticket price for the first interval.
@@ -47,28 +137,30 @@ func (s *simulator) calcNextStakeDiffProposal1() int64 {
 		return curDiff
 	}
 
-	curPoolSize := int64(s.tip.poolSize)
+	curPoolSize := int64(tip.PoolSize)
 	ratio := float64(curPoolSize) / float64(prevPoolSize)
 	return int64(float64(curDiff) * ratio)
 }
 
 // the algorithm proposed by raedah (enhanced older)
-func (s *simulator) calcNextStakeDiffProposal1E() int64 {
+func calcNextStakeDiffProposal1E(chain ChainView) int64 {
+	params, tip := chain.Params(), chain.Tip()
+
 	// Stake difficulty before any tickets could possibly be purchased is
 	// the minimum value.
 	nextHeight := int32(0)
-	if s.tip != nil {
-		nextHeight = s.tip.height + 1
+	if tip != nil {
+		nextHeight = tip.Height + 1
 	}
-	stakeDiffStartHeight := int32(s.params.CoinbaseMaturity) + 1
+	stakeDiffStartHeight := int32(params.CoinbaseMaturity) + 1
 	if nextHeight < stakeDiffStartHeight {
-		return s.params.MinimumStakeDiff
+		return params.MinimumStakeDiff
 	}
 
 	// Return the previous block's difficulty requirements if the next block
 	// is not at a difficulty retarget interval.
-	intervalSize := s.params.StakeDiffWindowSize
-	curDiff := s.tip.ticketPrice
+	intervalSize := params.StakeDiffWindowSize
+	curDiff := tip.TicketPrice
 	if int64(nextHeight)%intervalSize != 0 {
 		return curDiff
 	}
@@ -76,9 +168,9 @@ func (s *simulator) calcNextStakeDiffProposal1E() int64 {
 	// Attempt to get the pool size from the previous retarget interval.
 	var prevPoolSize int64
 	prevRetargetHeight := nextHeight - int32(intervalSize)
-	node := s.ancestorNode(s.tip, prevRetargetHeight, nil)
+	node := chain.Ancestor(prevRetargetHeight)
 	if node != nil {
-		prevPoolSize = int64(node.poolSize)
+		prevPoolSize = int64(node.PoolSize)
 	}
 
 	// Return the existing ticket price for the first interval.
@@ -88,25 +180,22 @@ func (s *simulator) calcNextStakeDiffProposal1E() int64 {
 
 	// get the immature ticket count from the previous window
 	// note, make sure we have no off-by-ones here
-	var prevImmatureTickets int64
-	ticketMaturity := int64(s.params.TicketMaturity)
-	relevantHeight := s.tip.height - int32(intervalSize) // or nextHeight?
-	relevantNode := s.ancestorNode(s.tip, relevantHeight, nil)
-	s.ancestorNode(relevantNode, relevantHeight-int32(ticketMaturity), func(n *blockNode) {
-		prevImmatureTickets += int64(len(n.ticketsAdded))
-	})
+	ticketMaturity := int64(params.TicketMaturity)
+	relevantHeight := tip.Height - int32(intervalSize) // or nextHeight?
+	prevImmatureTickets := chain.NewTickets(relevantHeight-
+		int32(ticketMaturity), relevantHeight-1)
 
 	// derive ratio of percent change in pool size
 	// max possible poolSizeChangeRatio is 2
-	immatureTickets := int64(len(s.immatureTickets))
-	curPoolSize := int64(s.tip.poolSize)
+	immatureTickets := int64(chain.ImmatureTickets())
+	curPoolSize := int64(tip.PoolSize)
 	curPoolSizeAll := curPoolSize + immatureTickets
 	prevPoolSizeAll := prevPoolSize + prevImmatureTickets
 	poolSizeChangeRatio := float64(curPoolSizeAll) / float64(prevPoolSizeAll)
 
 	// derive ratio of percent of target pool size
-	ticketsPerBlock := int64(s.params.TicketsPerBlock)
-	ticketPoolSize := int64(s.params.TicketPoolSize)
+	ticketsPerBlock := int64(params.TicketsPerBlock)
+	ticketPoolSize := int64(params.TicketPoolSize)
 	targetPoolSize := ticketsPerBlock * ticketPoolSize
 	targetPoolSizeAll := ticketsPerBlock * (ticketPoolSize + ticketMaturity)
 	targetRatio := float64(curPoolSizeAll) / float64(targetPoolSizeAll)
@@ -115,39 +204,41 @@ func (s *simulator) calcNextStakeDiffProposal1E() int64 {
 	nextDiff := float64(curDiff) * poolSizeChangeRatio * targetRatio
 
 	// insure the pool gets fully populated
-	maximumStakeDiff := int64(float64(s.tip.totalSupply) / float64(targetPoolSize))
+	maximumStakeDiff := int64(float64(tip.TotalSupply) / float64(targetPoolSize))
 	if int64(nextDiff) > maximumStakeDiff {
-		if maximumStakeDiff < s.params.MinimumStakeDiff {
-			return s.params.MinimumStakeDiff
+		if maximumStakeDiff < params.MinimumStakeDiff {
+			return params.MinimumStakeDiff
 		}
 		return maximumStakeDiff
 	}
 
 	// hard coded minimum value
-	if int64(nextDiff) < s.params.MinimumStakeDiff {
-		return s.params.MinimumStakeDiff
+	if int64(nextDiff) < params.MinimumStakeDiff {
+		return params.MinimumStakeDiff
 	}
 
 	return int64(nextDiff)
 }
 
 // the algorithm proposed by raedah (enhanced newer F)
-func (s *simulator) calcNextStakeDiffProposal1F() int64 {
+func calcNextStakeDiffProposal1F(chain ChainView) int64 {
+	params, tip := chain.Params(), chain.Tip()
+
 	// Stake difficulty before any tickets could possibly be purchased is
 	// the minimum value.
 	nextHeight := int32(0)
-	if s.tip != nil {
-		nextHeight = s.tip.height + 1
+	if tip != nil {
+		nextHeight = tip.Height + 1
 	}
-	stakeDiffStartHeight := int32(s.params.CoinbaseMaturity) + 1
+	stakeDiffStartHeight := int32(params.CoinbaseMaturity) + 1
 	if nextHeight < stakeDiffStartHeight {
-		return s.params.MinimumStakeDiff
+		return params.MinimumStakeDiff
 	}
 
 	// Return the previous block's difficulty requirements if the next block
 	// is not at a difficulty retarget interval.
-	intervalSize := s.params.StakeDiffWindowSize
-	curDiff := s.tip.ticketPrice
+	intervalSize := params.StakeDiffWindowSize
+	curDiff := tip.TicketPrice
 	if int64(nextHeight)%intervalSize != 0 {
 		return curDiff
 	}
@@ -155,9 +246,9 @@ func (s *simulator) calcNextStakeDiffProposal1F() int64 {
 	// Attempt to get the pool size from the previous retarget interval.
 	var prevPoolSize int64
 	prevRetargetHeight := nextHeight - int32(intervalSize)
-	node := s.ancestorNode(s.tip, prevRetargetHeight, nil)
+	node := chain.Ancestor(prevRetargetHeight)
 	if node != nil {
-		prevPoolSize = int64(node.poolSize)
+		prevPoolSize = int64(node.PoolSize)
 	}
 
 	// Return the existing ticket price for the first interval.
@@ -166,26 +257,23 @@ func (s *simulator) calcNextStakeDiffProposal1F() int64 {
 	}
 
 	// get the immature ticket count from the previous window
-	var prevImmatureTickets int64
-	ticketMaturity := int64(s.params.TicketMaturity)
-	relevantHeight := s.tip.height - int32(intervalSize)
-	relevantNode := s.ancestorNode(s.tip, relevantHeight, nil)
-	s.ancestorNode(relevantNode, relevantHeight-int32(ticketMaturity), func(n *blockNode) {
-		prevImmatureTickets += int64(len(n.ticketsAdded))
-	})
+	ticketMaturity := int64(params.TicketMaturity)
+	relevantHeight := tip.Height - int32(intervalSize)
+	prevImmatureTickets := chain.NewTickets(relevantHeight-
+		int32(ticketMaturity), relevantHeight-1)
 
 	// derive ratio of percent change in pool size
 	// max possible poolSizeChangeRatio is 2
-	immatureTickets := int64(len(s.immatureTickets))
-	curPoolSize := int64(s.tip.poolSize)
+	immatureTickets := int64(chain.ImmatureTickets())
+	curPoolSize := int64(tip.PoolSize)
 	curPoolSizeAll := curPoolSize + immatureTickets
 	prevPoolSizeAll := prevPoolSize + prevImmatureTickets
 	poolSizeChangeRatio := float64(curPoolSizeAll) / float64(prevPoolSizeAll)
 
 	// derive ratio of percent of target pool size
-	ticketsPerBlock := int64(s.params.TicketsPerBlock)
+	ticketsPerBlock := int64(params.TicketsPerBlock)
 	ticketsPerWindow := ticketsPerBlock * intervalSize
-	ticketPoolSize := int64(s.params.TicketPoolSize)
+	ticketPoolSize := int64(params.TicketPoolSize)
 	targetPoolSize := ticketsPerBlock * ticketPoolSize
 	targetPoolSizeAll := ticketsPerBlock * (ticketPoolSize + ticketMaturity)
 	targetRatio := float64(curPoolSizeAll) / float64(targetPoolSizeAll)
@@ -195,7 +283,7 @@ func (s *simulator) calcNextStakeDiffProposal1F() int64 {
 	if poolSizeChangeRatio < 1.0 {
 		// Upward price movements are stronger then downward movements.
 		// Add downward movements relative strength, for the market to respond and give its input.
-		maxFreshStakePerBlock := int64(s.params.MaxFreshStakePerBlock)
+		maxFreshStakePerBlock := int64(params.MaxFreshStakePerBlock)
 		maxFreshStakePerWindow := maxFreshStakePerBlock * intervalSize
 		buysPerVote := float64(maxFreshStakePerWindow) / float64(ticketsPerWindow)
 		sizeDiff := float64(prevPoolSizeAll) - float64(curPoolSizeAll)
@@ -208,44 +296,46 @@ func (s *simulator) calcNextStakeDiffProposal1F() int64 {
 	}
 
 	// ramp up price during initial pool population
-	maximumStakeDiff := int64(float64(s.tip.totalSupply) / float64(targetPoolSize))
+	maximumStakeDiff := int64(float64(tip.TotalSupply) / float64(targetPoolSize))
 	if int64(nextDiff) > maximumStakeDiff && targetRatio < 1.0 {
 		nextDiff = float64(maximumStakeDiff) * targetRatio
 	}
 
 	// optional
 	if int64(nextDiff) > maximumStakeDiff {
-		if maximumStakeDiff < s.params.MinimumStakeDiff {
-			return s.params.MinimumStakeDiff
+		if maximumStakeDiff < params.MinimumStakeDiff {
+			return params.MinimumStakeDiff
 		}
 		return maximumStakeDiff
 	}
 
 	// hard coded minimum value
-	if int64(nextDiff) < s.params.MinimumStakeDiff {
-		return s.params.MinimumStakeDiff
+	if int64(nextDiff) < params.MinimumStakeDiff {
+		return params.MinimumStakeDiff
 	}
 
 	return int64(nextDiff)
 }
 
 // the algorithm proposed by raedah (enhanced newer)
-func (s *simulator) calcNextStakeDiffProposal1G() int64 {
+func calcNextStakeDiffProposal1G(chain ChainView) int64 {
+	params, tip := chain.Params(), chain.Tip()
+
 	// Stake difficulty before any tickets could possibly be purchased is
 	// the minimum value.
 	nextHeight := int32(0)
-	if s.tip != nil {
-		nextHeight = s.tip.height + 1
+	if tip != nil {
+		nextHeight = tip.Height + 1
 	}
-	stakeDiffStartHeight := int32(s.params.CoinbaseMaturity) + 1
+	stakeDiffStartHeight := int32(params.CoinbaseMaturity) + 1
 	if nextHeight < stakeDiffStartHeight {
-		return s.params.MinimumStakeDiff
+		return params.MinimumStakeDiff
 	}
 
 	// Return the previous block's difficulty requirements if the next block
 	// is not at a difficulty retarget interval.
-	intervalSize := s.params.StakeDiffWindowSize
-	curDiff := s.tip.ticketPrice
+	intervalSize := params.StakeDiffWindowSize
+	curDiff := tip.TicketPrice
 	if int64(nextHeight)%intervalSize != 0 {
 		return curDiff
 	}
@@ -253,9 +343,9 @@ func (s *simulator) calcNextStakeDiffProposal1G() int64 {
 	// Attempt to get the pool size from the previous retarget interval.
 	var prevPoolSize int64
 	prevRetargetHeight := nextHeight - int32(intervalSize)
-	node := s.ancestorNode(s.tip, prevRetargetHeight, nil)
+	node := chain.Ancestor(prevRetargetHeight)
 	if node != nil {
-		prevPoolSize = int64(node.poolSize)
+		prevPoolSize = int64(node.PoolSize)
 	}
 
 	// Return the existing ticket price for the first interval.
@@ -265,32 +355,29 @@ func (s *simulator) calcNextStakeDiffProposal1G() int64 {
 
 	// get the immature ticket count from the previous window
 	// note, make sure we have no off-by-ones here
-	var prevImmatureTickets int64
-	ticketMaturity := int64(s.params.TicketMaturity)
-	relevantHeight := s.tip.height - int32(intervalSize) // or nextHeight?
-	relevantNode := s.ancestorNode(s.tip, relevantHeight, nil)
-	s.ancestorNode(relevantNode, relevantHeight-int32(ticketMaturity), func(n *blockNode) {
-		prevImmatureTickets += int64(len(n.ticketsAdded))
-	})
+	ticketMaturity := int64(params.TicketMaturity)
+	relevantHeight := tip.Height - int32(intervalSize) // or nextHeight?
+	prevImmatureTickets := chain.NewTickets(relevantHeight-
+		int32(ticketMaturity), relevantHeight-1)
 
 	// derive ratio of percent change in pool size
 	// max possible poolSizeChangeRatio is 2
-	immatureTickets := int64(len(s.immatureTickets))
-	curPoolSize := int64(s.tip.poolSize)
+	immatureTickets := int64(chain.ImmatureTickets())
+	curPoolSize := int64(tip.PoolSize)
 	curPoolSizeAll := curPoolSize + immatureTickets
 	prevPoolSizeAll := prevPoolSize + prevImmatureTickets
 	poolSizeChangeRatio := float64(curPoolSizeAll) / float64(prevPoolSizeAll)
 
 	// derive ratio of percent of target pool size
-	ticketsPerBlock := int64(s.params.TicketsPerBlock)
+	ticketsPerBlock := int64(params.TicketsPerBlock)
 	ticketsPerWindow := ticketsPerBlock * intervalSize
-	ticketPoolSize := int64(s.params.TicketPoolSize)
+	ticketPoolSize := int64(params.TicketPoolSize)
 	targetPoolSize := ticketsPerBlock * ticketPoolSize
 	targetPoolSizeAll := ticketsPerBlock * (ticketPoolSize + ticketMaturity)
 	targetRatio := float64(curPoolSizeAll) / float64(targetPoolSizeAll)
 
 	// derive ratio of purchase slots filled
-	maxFreshStakePerBlock := int64(s.params.MaxFreshStakePerBlock)
+	maxFreshStakePerBlock := int64(params.MaxFreshStakePerBlock)
 	maxFreshStakePerWindow := maxFreshStakePerBlock * intervalSize
 	freshStakeLastWindow := curPoolSizeAll - prevPoolSizeAll
 	// steady is a consistent flow of tickets in and out
@@ -328,44 +415,46 @@ func (s *simulator) calcNextStakeDiffProposal1G() int64 {
 	}
 
 	// ramp up price during initial pool population
-	maximumStakeDiff := int64(float64(s.tip.totalSupply) / float64(targetPoolSize))
+	maximumStakeDiff := int64(float64(tip.TotalSupply) / float64(targetPoolSize))
 	if int64(nextDiff) > maximumStakeDiff && targetRatio < 1.0 {
 		nextDiff = float64(maximumStakeDiff) * targetRatio
 	}
 
 	// optional
 	if int64(nextDiff) > maximumStakeDiff {
-		if maximumStakeDiff < s.params.MinimumStakeDiff {
-			return s.params.MinimumStakeDiff
+		if maximumStakeDiff < params.MinimumStakeDiff {
+			return params.MinimumStakeDiff
 		}
 		return maximumStakeDiff
 	}
 
 	// Hard coded minimum value.
-	if int64(nextDiff) < s.params.MinimumStakeDiff {
-		return s.params.MinimumStakeDiff
+	if int64(nextDiff) < params.MinimumStakeDiff {
+		return params.MinimumStakeDiff
 	}
 
 	return int64(nextDiff)
 }
 
 // The algorithm proposed by raedah (v4)
-func (s *simulator) calcNextStakeDiffProposal1H() int64 {
+func calcNextStakeDiffProposal1H(chain ChainView) int64 {
+	params, tip := chain.Params(), chain.Tip()
+
 	// Stake difficulty before any tickets could possibly be purchased is
 	// the minimum value.
 	nextHeight := int32(0)
-	if s.tip != nil {
-		nextHeight = s.tip.height + 1
+	if tip != nil {
+		nextHeight = tip.Height + 1
 	}
-	stakeDiffStartHeight := int32(s.params.CoinbaseMaturity) + 1
+	stakeDiffStartHeight := int32(params.CoinbaseMaturity) + 1
 	if nextHeight < stakeDiffStartHeight {
-		return s.params.MinimumStakeDiff
+		return params.MinimumStakeDiff
 	}
 
 	// Return the previous block's difficulty requirements if the next block
 	// is not at a difficulty retarget interval.
-	intervalSize := s.params.StakeDiffWindowSize
-	curDiff := s.tip.ticketPrice
+	intervalSize := params.StakeDiffWindowSize
+	curDiff := tip.TicketPrice
 	if int64(nextHeight)%intervalSize != 0 {
 		return curDiff
 	}
@@ -373,17 +462,15 @@ func (s *simulator) calcNextStakeDiffProposal1H() int64 {
 	// Attempt to get the pool size from the previous retarget interval.
 	var prevPoolSize int64
 	prevRetargetHeight := nextHeight - int32(intervalSize)
-	node := s.ancestorNode(s.tip, prevRetargetHeight, nil)
+	node := chain.Ancestor(prevRetargetHeight)
 	if node != nil {
-		prevPoolSize = int64(node.poolSize)
+		prevPoolSize = int64(node.PoolSize)
 	}
 
 	// Get the immature ticket count from the previous interval.
-	var prevImmatureTickets int64
-	ticketMaturity := int64(s.params.TicketMaturity)
-	s.ancestorNode(node, node.height-int32(ticketMaturity), func(n *blockNode) {
-		prevImmatureTickets += int64(len(n.ticketsAdded))
-	})
+	ticketMaturity := int64(params.TicketMaturity)
+	prevImmatureTickets := chain.NewTickets(node.Height-int32(ticketMaturity),
+		node.Height-1)
 
 	// Return the existing ticket price for the first interval.
 	if prevPoolSize+prevImmatureTickets == 0 {
@@ -391,14 +478,14 @@ func (s *simulator) calcNextStakeDiffProposal1H() int64 {
 	}
 
 	// Pool size amounts.
-	immatureTickets := int64(len(s.immatureTickets))
-	curPoolSize := int64(s.tip.poolSize)
+	immatureTickets := int64(chain.ImmatureTickets())
+	curPoolSize := int64(tip.PoolSize)
 	curPoolSizeAll := curPoolSize + immatureTickets
 	prevPoolSizeAll := prevPoolSize + prevImmatureTickets
 
 	// Ratio of the current pool size to the desired target pool size.
-	ticketsPerBlock := int64(s.params.TicketsPerBlock)
-	ticketPoolSize := int64(s.params.TicketPoolSize)
+	ticketsPerBlock := int64(params.TicketsPerBlock)
+	ticketPoolSize := int64(params.TicketPoolSize)
 	targetPoolSize := ticketsPerBlock * ticketPoolSize
 	targetPoolSizeAll := ticketsPerBlock * (ticketPoolSize + ticketMaturity)
 	targetRatio := float64(curPoolSizeAll) / float64(targetPoolSizeAll)
@@ -424,7 +511,7 @@ func (s *simulator) calcNextStakeDiffProposal1H() int64 {
 	nextDiff := float64(curDiff) * relativeBoost * targetRatio
 
 	// Ramp up price during initial pool population.
-	maximumStakeDiff := int64(float64(s.tip.totalSupply) / float64(targetPoolSize))
+	maximumStakeDiff := int64(float64(tip.TotalSupply) / float64(targetPoolSize))
 	if int64(nextDiff) > maximumStakeDiff && targetRatio < 1.0 {
 		nextDiff = float64(maximumStakeDiff) * targetRatio
 	}
@@ -433,37 +520,39 @@ func (s *simulator) calcNextStakeDiffProposal1H() int64 {
 	// Trades off pool size spike, with insuring the pool gets fully populated.
 	// Also keeps the chart scale more readable.
 	if int64(nextDiff) > maximumStakeDiff {
-		if maximumStakeDiff < s.params.MinimumStakeDiff {
-			return s.params.MinimumStakeDiff
+		if maximumStakeDiff < params.MinimumStakeDiff {
+			return params.MinimumStakeDiff
 		}
 		return maximumStakeDiff
 	}
 
 	// Hard coded minimum value.
-	if int64(nextDiff) < s.params.MinimumStakeDiff {
-		return s.params.MinimumStakeDiff
+	if int64(nextDiff) < params.MinimumStakeDiff {
+		return params.MinimumStakeDiff
 	}
 
 	return int64(nextDiff)
 }
 
 // The algorithm proposed by raedah (v5)
-func (s *simulator) calcNextStakeDiffProposal1R() int64 {
+func calcNextStakeDiffProposal1R(chain ChainView) int64 {
+	params, tip := chain.Params(), chain.Tip()
+
 	// Stake difficulty before any tickets could possibly be purchased is
 	// the minimum value.
 	nextHeight := int32(0)
-	if s.tip != nil {
-		nextHeight = s.tip.height + 1
+	if tip != nil {
+		nextHeight = tip.Height + 1
 	}
-	stakeDiffStartHeight := int32(s.params.CoinbaseMaturity) + 1
+	stakeDiffStartHeight := int32(params.CoinbaseMaturity) + 1
 	if nextHeight < stakeDiffStartHeight {
-		return s.params.MinimumStakeDiff
+		return params.MinimumStakeDiff
 	}
 
 	// Return the previous block's difficulty requirements if the next block
 	// is not at a difficulty retarget interval.
-	intervalSize := s.params.StakeDiffWindowSize
-	curDiff := s.tip.ticketPrice
+	intervalSize := params.StakeDiffWindowSize
+	curDiff := tip.TicketPrice
 	if int64(nextHeight)%intervalSize != 0 {
 		return curDiff
 	}
@@ -471,17 +560,15 @@ func (s *simulator) calcNextStakeDiffProposal1R() int64 {
 	// Attempt to get the pool size from the previous retarget interval.
 	var prevPoolSize int64
 	prevRetargetHeight := nextHeight - int32(intervalSize)
-	node := s.ancestorNode(s.tip, prevRetargetHeight, nil)
+	node := chain.Ancestor(prevRetargetHeight)
 	if node != nil {
-		prevPoolSize = int64(node.poolSize)
+		prevPoolSize = int64(node.PoolSize)
 	}
 
 	// Get the immature ticket count from the previous interval.
-	var prevImmatureTickets int64
-	ticketMaturity := int64(s.params.TicketMaturity)
-	s.ancestorNode(node, node.height-int32(ticketMaturity), func(n *blockNode) {
-		prevImmatureTickets += int64(len(n.ticketsAdded))
-	})
+	ticketMaturity := int64(params.TicketMaturity)
+	prevImmatureTickets := chain.NewTickets(node.Height-int32(ticketMaturity),
+		node.Height-1)
 
 	// Return the existing ticket price for the first interval.
 	if prevPoolSize+prevImmatureTickets == 0 {
@@ -489,14 +576,14 @@ func (s *simulator) calcNextStakeDiffProposal1R() int64 {
 	}
 
 	// Pool size amounts.
-	immatureTickets := int64(len(s.immatureTickets))
-	curPoolSize := int64(s.tip.poolSize)
+	immatureTickets := int64(chain.ImmatureTickets())
+	curPoolSize := int64(tip.PoolSize)
 	curPoolSizeAll := curPoolSize + immatureTickets
 	prevPoolSizeAll := prevPoolSize + prevImmatureTickets
 
 	// Ratio of the current pool size to the desired target pool size.
-	ticketsPerBlock := int64(s.params.TicketsPerBlock)
-	ticketPoolSize := int64(s.params.TicketPoolSize)
+	ticketsPerBlock := int64(params.TicketsPerBlock)
+	ticketPoolSize := int64(params.TicketPoolSize)
 	//targetPoolSize := ticketsPerBlock * ticketPoolSize
 	targetPoolSizeAll := ticketsPerBlock * (ticketPoolSize + ticketMaturity)
 	targetRatio := float64(curPoolSizeAll) / float64(targetPoolSizeAll)
@@ -528,7 +615,7 @@ func (s *simulator) calcNextStakeDiffProposal1R() int64 {
 
 	// Ramp up price during initial pool population.
 	// Insures the pool gets fully populated.
-	maximumStakeDiff := int64(float64(s.tip.totalSupply) / float64(targetPoolSizeAll))
+	maximumStakeDiff := int64(float64(tip.TotalSupply) / float64(targetPoolSizeAll))
 	if float64(nextDiff) > float64(maximumStakeDiff)*targetRatio {
 		nextDiff = float64(maximumStakeDiff) * targetRatio
 	}
@@ -537,40 +624,42 @@ func (s *simulator) calcNextStakeDiffProposal1R() int64 {
 	// Insures the pool gets fully populated, but not needed if above code is used.
 	// Keep the chart scale more readable, but can allow the pool size to rise slightly higher.
 	if int64(nextDiff) > maximumStakeDiff {
-		if maximumStakeDiff < s.params.MinimumStakeDiff {
-			return s.params.MinimumStakeDiff
+		if maximumStakeDiff < params.MinimumStakeDiff {
+			return params.MinimumStakeDiff
 		}
 		return maximumStakeDiff
 	}
 
 	// Hard coded minimum value.
-	if int64(nextDiff) < s.params.MinimumStakeDiff {
-		return s.params.MinimumStakeDiff
+	if int64(nextDiff) < params.MinimumStakeDiff {
+		return params.MinimumStakeDiff
 	}
 
 	return int64(nextDiff)
 }
 
 // calcNextStakeDiffProposal2 returns the required stake difficulty (aka ticket
-// price) for the block after the current tip block the simulator is associated
-// with using the algorithm proposed by animedow in
+// price) for the block after the tip of the passed chain view using the
+// algorithm proposed by animedow in
 // https://github.com/decred/dcrd/issues/584
-func (s *simulator) calcNextStakeDiffProposal2() int64 {
+func calcNextStakeDiffProposal2(chain ChainView) int64 {
+	params, tip := chain.Params(), chain.Tip()
+
 	// Stake difficulty before any tickets could possibly be purchased is
 	// the minimum value.
 	nextHeight := int32(0)
-	if s.tip != nil {
-		nextHeight = s.tip.height + 1
+	if tip != nil {
+		nextHeight = tip.Height + 1
 	}
-	stakeDiffStartHeight := int32(s.params.CoinbaseMaturity) + 1
+	stakeDiffStartHeight := int32(params.CoinbaseMaturity) + 1
 	if nextHeight < stakeDiffStartHeight {
-		return s.params.MinimumStakeDiff
+		return params.MinimumStakeDiff
 	}
 
 	// Return the previous block's difficulty requirements if the next block
 	// is not at a difficulty retarget interval.
-	intervalSize := s.params.StakeDiffWindowSize
-	curDiff := s.tip.ticketPrice
+	intervalSize := params.StakeDiffWindowSize
+	curDiff := tip.TicketPrice
 	if int64(nextHeight)%intervalSize != 0 {
 		return curDiff
 	}
@@ -584,111 +673,100 @@ func (s *simulator) calcNextStakeDiffProposal2() int64 {
 	// b = the maximum boundary;
 	// c = the minimum boundary;
 	// d = the average ticket price in pool.
-	x := int64(s.tip.poolSize) - (int64(s.params.TicketsPerBlock) *
-		int64(s.params.TicketPoolSize))
+	x := int64(tip.PoolSize) - (int64(params.TicketsPerBlock) *
+		int64(params.TicketPoolSize))
 	a := int64(100000)
 	b := int64(2880)
 	c := int64(2880)
-	var d int64
-	var totalSpent int64
-	totalTickets := int64(len(s.immatureTickets) + s.liveTickets.Len())
-	if totalTickets != 0 {
-		for _, ticket := range s.immatureTickets {
-			totalSpent += int64(ticket.price)
-		}
-		s.liveTickets.ForEach(func(k tickettreap.Key, v *tickettreap.Value) bool {
-			totalSpent += v.PurchasePrice
-			return true
-		})
-		d = totalSpent / totalTickets
-	}
+	d := chain.AvgTicketPrice()
 	price := int64(float64(d) - 100000000*(float64(a*x)/float64((x-b)*(x+c))))
-	if price < s.params.MinimumStakeDiff {
-		price = s.params.MinimumStakeDiff
+	if price < params.MinimumStakeDiff {
+		price = params.MinimumStakeDiff
 	}
 	return price
 }
 
 // calcNextStakeDiffProposal3 returns the required stake difficulty (aka ticket
-// price) for the block after the current tip block the simulator is associated
-// with using the algorithm proposed by coblee in
+// price) for the block after the tip of the passed chain view using the
+// algorithm proposed by coblee in
 // https://github.com/decred/dcrd/issues/584
-func (s *simulator) calcNextStakeDiffProposal3() int64 {
+func calcNextStakeDiffProposal3(chain ChainView) int64 {
+	params, tip := chain.Params(), chain.Tip()
+
 	// Stake difficulty before any tickets could possibly be purchased is
 	// the minimum value.
 	nextHeight := int32(0)
-	if s.tip != nil {
-		nextHeight = s.tip.height + 1
+	if tip != nil {
+		nextHeight = tip.Height + 1
 	}
-	stakeDiffStartHeight := int32(s.params.CoinbaseMaturity) + 1
+	stakeDiffStartHeight := int32(params.CoinbaseMaturity) + 1
 	if nextHeight < stakeDiffStartHeight {
-		return s.params.MinimumStakeDiff
+		return params.MinimumStakeDiff
 	}
 
 	// Return the previous block's difficulty requirements if the next block
 	// is not at a difficulty retarget interval.
-	intervalSize := s.params.StakeDiffWindowSize
-	curDiff := s.tip.ticketPrice
+	intervalSize := params.StakeDiffWindowSize
+	curDiff := tip.TicketPrice
 	if int64(nextHeight)%intervalSize != 0 {
 		return curDiff
 	}
 
 	// f(x) = x*(locked/target_pool_size) + (1-x)*(locked/pool_size_actual)
-	ticketsPerBlock := int64(s.params.TicketsPerBlock)
-	targetPoolSize := ticketsPerBlock * int64(s.params.TicketPoolSize)
-	lockedSupply := s.tip.stakedCoins
+	ticketsPerBlock := int64(params.TicketsPerBlock)
+	targetPoolSize := ticketsPerBlock * int64(params.TicketPoolSize)
+	lockedSupply := tip.StakedCoins
 	x := int64(1)
 	var price int64
-	if s.tip.poolSize == 0 {
+	if tip.PoolSize == 0 {
 		price = int64(lockedSupply) / targetPoolSize
 	} else {
 		price = x*int64(lockedSupply)/targetPoolSize +
-			(1-x)*(int64(lockedSupply)/int64(s.tip.poolSize))
+			(1-x)*(int64(lockedSupply)/int64(tip.PoolSize))
 	}
-	if price < s.params.MinimumStakeDiff {
-		price = s.params.MinimumStakeDiff
+	if price < params.MinimumStakeDiff {
+		price = params.MinimumStakeDiff
 	}
 	return price
 }
 
 // calcNextStakeDiffProposal4 returns the required stake difficulty (aka ticket
-// price) for the block after the current tip block the simulator is associated
-// with using the algorithm proposed by jyap808 in
+// price) for the block after the tip of the passed chain view using the
+// algorithm proposed by jyap808 in
 // https://github.com/decred/dcrd/issues/584
-func (s *simulator) calcNextStakeDiffProposal4() int64 {
+func calcNextStakeDiffProposal4(chain ChainView) int64 {
+	params, tip := chain.Params(), chain.Tip()
+
 	// Stake difficulty before any tickets could possibly be purchased is
 	// the minimum value.
 	nextHeight := int32(0)
-	if s.tip != nil {
-		nextHeight = s.tip.height + 1
+	if tip != nil {
+		nextHeight = tip.Height + 1
 	}
 
-	stakeDiffStartHeight := int32(s.params.CoinbaseMaturity) + 1
+	stakeDiffStartHeight := int32(params.CoinbaseMaturity) + 1
 	if nextHeight < stakeDiffStartHeight {
-		return s.params.MinimumStakeDiff
+		return params.MinimumStakeDiff
 	}
 
 	// Return the previous block's difficulty requirements if the next block
 	// is not at a difficulty retarget interval.
-	intervalSize := s.params.StakeDiffWindowSize
-	curDiff := s.tip.ticketPrice
+	intervalSize := params.StakeDiffWindowSize
+	curDiff := tip.TicketPrice
 	if int64(nextHeight)%intervalSize != 0 {
 		return curDiff
 	}
 
 	// Get the number of tickets purchased in the previous interval.
-	var ticketsPurchased int64
-	prevRetargetHeight := s.tip.height - int32(intervalSize)
-	s.ancestorNode(s.tip, prevRetargetHeight, func(node *blockNode) {
-		ticketsPurchased += int64(len(node.ticketsAdded))
-	})
+	prevRetargetHeight := tip.Height - int32(intervalSize)
+	ticketsPurchased := chain.NewTickets(prevRetargetHeight, tip.Height-1)
 
 	// Shorter versions of useful params for convenience.
-	votesPerBlock := int64(s.params.TicketsPerBlock)
-	votesPerInterval := votesPerBlock * int64(s.params.TicketPoolSize)
-	maxTicketsPerBlock := int64(s.params.MaxFreshStakePerBlock)
-	maxTicketsPerInterval := maxTicketsPerBlock * int64(s.params.TicketPoolSize)
-	targetPoolSize := votesPerBlock * int64(s.params.TicketPoolSize)
+	votesPerBlock := int64(params.TicketsPerBlock)
+	votesPerInterval := votesPerBlock * int64(params.TicketPoolSize)
+	maxTicketsPerBlock := int64(params.MaxFreshStakePerBlock)
+	maxTicketsPerInterval := maxTicketsPerBlock * int64(params.TicketPoolSize)
+	targetPoolSize := votesPerBlock * int64(params.TicketPoolSize)
 
 	// Formulas provided by proposal.
 	//
@@ -705,84 +783,123 @@ func (s *simulator) calcNextStakeDiffProposal4() int64 {
 		float64(maxTicketsPerInterval)
 	scalingFactor := float64(ticketsPurchased-votesPerInterval) /
 		float64(maxTicketsPerInterval-votesPerInterval)
-	if targetPoolSize >= int64(s.tip.poolSize) {
+	if targetPoolSize >= int64(tip.PoolSize) {
 		nextDiff = int64(float64(curDiff) + (bounds * scalingFactor))
 	} else {
 		nextDiff = int64(float64(curDiff) + (-bounds * scalingFactor))
 	}
 
-	if nextDiff < s.params.MinimumStakeDiff {
-		nextDiff = s.params.MinimumStakeDiff
+	if nextDiff < params.MinimumStakeDiff {
+		nextDiff = params.MinimumStakeDiff
 	}
 	return nextDiff
 }
 
-var integral = 0.0
-var previousError = 0.0
+// pidStakeDiff houses the state of the PID controller used by the algorithm
+// proposed by edsonbrusque in https://github.com/decred/dcrd/issues/584
+//
+// The integral and previous error are updated each time the price is
+// calculated for a retarget, exactly as the algorithm was originally
+// implemented.  Implementing StatefulStakeDiffAlgorithm allows that state to be
+// included in snapshots of the simulator.
+type pidStakeDiff struct {
+	integral      float64
+	previousError float64
+}
+
+// Ensure pidStakeDiff implements the StatefulStakeDiffAlgorithm interface.
+var _ StatefulStakeDiffAlgorithm = (*pidStakeDiff)(nil)
+
+// NextStakeDiff returns the required stake difficulty (aka ticket price) for
+// the block after the tip of the passed chain view using the algorithm proposed
+// by edsonbrusque.  It is part of the StakeDiffAlgorithm interface
+// implementation.
+func (pid *pidStakeDiff) NextStakeDiff(chain ChainView) int64 {
+	params, tip := chain.Params(), chain.Tip()
 
-// calcNextStakeDiffProposal5 returns the required stake difficulty (aka ticket
-// price) for the block after the current tip block the simulator is associated
-// with using the algorithm proposed by edsonbrusque in
-// https://github.com/decred/dcrd/issues/584
-func (s *simulator) calcNextStakeDiffProposal5() int64 {
 	// Stake difficulty before any tickets could possibly be purchased is
 	// the minimum value.
 	nextHeight := int32(0)
-	if s.tip != nil {
-		nextHeight = s.tip.height + 1
+	if tip != nil {
+		nextHeight = tip.Height + 1
 	}
 
-	stakeDiffStartHeight := int32(s.params.CoinbaseMaturity) + 1
+	stakeDiffStartHeight := int32(params.CoinbaseMaturity) + 1
 	if nextHeight < stakeDiffStartHeight {
-		return s.params.MinimumStakeDiff
+		return params.MinimumStakeDiff
 	}
 
 	// Return the previous block's difficulty requirements if the next block
 	// is not at a difficulty retarget interval.
-	intervalSize := s.params.StakeDiffWindowSize
-	curDiff := s.tip.ticketPrice
+	intervalSize := params.StakeDiffWindowSize
+	curDiff := tip.TicketPrice
 	if int64(nextHeight)%intervalSize != 0 {
 		return curDiff
 	}
 
-	ticketsPerBlock := int64(s.params.TicketsPerBlock)
-	targetPoolSize := ticketsPerBlock * int64(s.params.TicketPoolSize)
+	ticketsPerBlock := int64(params.TicketsPerBlock)
+	targetPoolSize := ticketsPerBlock * int64(params.TicketPoolSize)
 
 	Kp := 0.0017
 	Ki := 0.00005
 	Kd := 0.0024
-	e := float64(int64(s.tip.poolSize) - targetPoolSize)
-	integral = integral + e
-	derivative := (e - previousError)
-	nextDiff := int64(dcrutil.AtomsPerCoin * (e*Kp + integral*Ki + derivative*Kd))
-	previousError = e
+	e := float64(int64(tip.PoolSize) - targetPoolSize)
+	pid.integral = pid.integral + e
+	derivative := (e - pid.previousError)
+	nextDiff := int64(dcrutil.AtomsPerCoin * (e*Kp + pid.integral*Ki + derivative*Kd))
+	pid.previousError = e
 
-	if nextDiff < s.params.MinimumStakeDiff {
-		nextDiff = s.params.MinimumStakeDiff
+	if nextDiff < params.MinimumStakeDiff {
+		nextDiff = params.MinimumStakeDiff
 	}
 	return nextDiff
 }
 
+// BlockConnected does nothing since the PID controller updates its state while
+// calculating the price.  It is part of the StatefulStakeDiffAlgorithm
+// interface implementation.
+func (pid *pidStakeDiff) BlockConnected(chain ChainView) {}
+
+// Retarget does nothing since the PID controller updates its state while
+// calculating the price.  It is part of the StatefulStakeDiffAlgorithm
+// interface implementation.
+func (pid *pidStakeDiff) Retarget(chain ChainView) {}
+
+// Snapshot returns a copy of the state of the PID controller.  It is part of
+// the StatefulStakeDiffAlgorithm interface implementation.
+func (pid *pidStakeDiff) Snapshot() interface{} {
+	return *pid
+}
+
+// Restore replaces the state of the PID controller with the passed state
+// returned by Snapshot.  It is part of the StatefulStakeDiffAlgorithm interface
+// implementation.
+func (pid *pidStakeDiff) Restore(state interface{}) {
+	*pid = state.(pidStakeDiff)
+}
+
 // calcNextStakeDiffProposal6 returns the required stake difficulty (aka ticket
-// price) for the block after the current tip block the simulator is associated
-// with using the algorithm proposed by chappjc in
+// price) for the block after the tip of the passed chain view using the
+// algorithm proposed by chappjc in
 // https://github.com/decred/dcrd/issues/584
-func (s *simulator) calcNextStakeDiffProposal6() int64 {
+func calcNextStakeDiffProposal6(chain ChainView) int64 {
+	params, tip := chain.Params(), chain.Tip()
+
 	// Stake difficulty before any tickets could possibly be purchased is
 	// the minimum value.
 	nextHeight := int32(0)
-	if s.tip != nil {
-		nextHeight = s.tip.height + 1
+	if tip != nil {
+		nextHeight = tip.Height + 1
 	}
-	stakeDiffStartHeight := int32(s.params.CoinbaseMaturity) + 1
+	stakeDiffStartHeight := int32(params.CoinbaseMaturity) + 1
 	if nextHeight < stakeDiffStartHeight {
-		return s.params.MinimumStakeDiff
+		return params.MinimumStakeDiff
 	}
 
 	// Return the previous block's difficulty requirements if the next block
 	// is not at a difficulty retarget interval.
-	intervalSize := s.params.StakeDiffWindowSize
-	curDiff := s.tip.ticketPrice
+	intervalSize := params.StakeDiffWindowSize
+	curDiff := tip.TicketPrice
 	if int64(nextHeight)%intervalSize != 0 {
 		return curDiff
 	}
@@ -799,13 +916,13 @@ func (s *simulator) calcNextStakeDiffProposal6() int64 {
 	// Attempt to get the pool size from the previous retarget interval.
 	//
 	// NOTE: This is off by one.  It should be nextHeight instead of
-	// s.tip.height, but it has been left incorrect to match the original
+	// tip.Height, but it has been left incorrect to match the original
 	// proposal code.
 	var p, q int64
-	prevRetargetHeight := s.tip.height - int32(intervalSize)
-	if node := s.ancestorNode(s.tip, prevRetargetHeight, nil); node != nil {
-		p = int64(node.poolSize)
-		q = node.ticketPrice
+	prevRetargetHeight := tip.Height - int32(intervalSize)
+	if node := chain.Ancestor(prevRetargetHeight); node != nil {
+		p = int64(node.PoolSize)
+		q = node.TicketPrice
 	}
 
 	// Return the existing ticket price for the first interval.
@@ -813,12 +930,12 @@ func (s *simulator) calcNextStakeDiffProposal6() int64 {
 		return curDiff
 	}
 
-	c := int64(s.tip.poolSize) + int64(len(s.immatureTickets))
-	t := int64(s.params.TicketsPerBlock) * int64(s.params.TicketPoolSize)
+	c := int64(tip.PoolSize) + int64(chain.ImmatureTickets())
+	t := int64(params.TicketsPerBlock) * int64(params.TicketPoolSize)
 
 	// Useful ticket counts are A (-5 * 144) and B (15 * 144)
-	//A := -int64(s.params.TicketsPerBlock) * intervalSize
-	B := (int64(s.params.MaxFreshStakePerBlock) - int64(s.params.TicketsPerBlock)) * intervalSize
+	//A := -int64(params.TicketsPerBlock) * intervalSize
+	B := (int64(params.MaxFreshStakePerBlock) - int64(params.TicketsPerBlock)) * intervalSize
 	t += 1280 // not B (1440)?
 
 	// Pool velocity
@@ -827,14 +944,12 @@ func (s *simulator) calcNextStakeDiffProposal6() int64 {
 	// live count.
 	//
 	// NOTE: This is off by one.  It should be nextHeight instead of
-	// s.tip.height, but it has been left incorrect to match the original
+	// tip.Height, but it has been left incorrect to match the original
 	// proposal code.
-	var immprev int64
-	ticketMaturity := int64(s.params.TicketMaturity)
-	node := s.ancestorNode(s.tip, s.tip.height-int32(intervalSize), nil)
-	s.ancestorNode(node, node.height-int32(ticketMaturity), func(n *blockNode) {
-		immprev += int64(len(n.ticketsAdded))
-	})
+	ticketMaturity := int64(params.TicketMaturity)
+	node := chain.Ancestor(tip.Height - int32(intervalSize))
+	immprev := chain.NewTickets(node.Height-int32(ticketMaturity),
+		node.Height-1)
 	p += immprev
 
 	// Pool size change over last intervalSize blocks
@@ -867,7 +982,7 @@ func (s *simulator) calcNextStakeDiffProposal6() int64 {
 	// Mapped onto (0,1] by an exponential decay
 	m := math.Exp(-absPriceDeltaLast * 2) // m = 80% ~= exp((10% delta) *-2)
 	// NOTE: make this stochastic by replacing the number 8 something like
-	// (rand.NewSource(s.tip.ticketPrice).Int63() >> 59)
+	// (rand.NewSource(tip.TicketPrice).Int63() >> 59)
 
 	// Scale directional (signed) pool force with the exponentially-mapped
 	// price derivative. Interpret the scalar input parameter as a percent
@@ -877,10 +992,10 @@ func (s *simulator) calcNextStakeDiffProposal6() int64 {
 	n := float64(curDiff) * (1.0 + pctChange) * poolDelta
 
 	// Enforce minimum and maximum prices.
-	pMax := int64(s.tip.totalSupply) / int64(s.params.TicketPoolSize)
+	pMax := int64(tip.TotalSupply) / int64(params.TicketPoolSize)
 	price := int64(n)
-	if price < s.params.MinimumStakeDiff {
-		price = s.params.MinimumStakeDiff
+	if price < params.MinimumStakeDiff {
+		price = params.MinimumStakeDiff
 	} else if price > pMax {
 		price = pMax
 	}
@@ -889,13 +1004,14 @@ func (s *simulator) calcNextStakeDiffProposal6() int64 {
 }
 
 // estimateSupply returns an estimate of the coin supply for the provided block
-// height.  This is primarily used in the stake difficulty algorithm and relies
-// on an estimate to simplify the necessary calculations.  The actual total
-// coin supply as of a given block height depends on many factors such as the
-// number of votes included in every prior block (not including all votes
-// reduces the subsidy) and whether or not any of the prior blocks have been
-// invalidated by stakeholders thereby removing the PoW subsidy for the them.
-func (s *simulator) estimateSupply(height int32) dcrutil.Amount {
+// height on the network defined by the passed params.  This is primarily used
+// in the stake difficulty algorithm and relies on an estimate to simplify the
+// necessary calculations.  The actual total coin supply as of a given block
+// height depends on many factors such as the number of votes included in every
+// prior block (not including all votes reduces the subsidy) and whether or not
+// any of the prior blocks have been invalidated by stakeholders thereby
+// removing the PoW subsidy for the them.
+func estimateSupply(params *chaincfg.Params, height int32) dcrutil.Amount {
 	if height <= 0 {
 		return 0
 	}
@@ -904,45 +1020,47 @@ func (s *simulator) estimateSupply(height int32) dcrutil.Amount {
 	// reduction interval and multiplying it the number of blocks in the
 	// interval then adding the subsidy produced by number of blocks in the
 	// current interval.
-	supply := s.params.BlockOneSubsidy()
-	reductions := int64(height) / s.params.SubsidyReductionInterval
-	subsidy := s.params.BaseSubsidy
+	supply := params.BlockOneSubsidy()
+	reductions := int64(height) / params.SubsidyReductionInterval
+	subsidy := params.BaseSubsidy
 	for i := int64(0); i < reductions; i++ {
-		supply += s.params.SubsidyReductionInterval * subsidy
+		supply += params.SubsidyReductionInterval * subsidy
 
-		subsidy *= s.params.MulSubsidy
-		subsidy /= s.params.DivSubsidy
+		subsidy *= params.MulSubsidy
+		subsidy /= params.DivSubsidy
 	}
-	supply += (int64(height) % s.params.SubsidyReductionInterval) * subsidy
+	supply += (int64(height) % params.SubsidyReductionInterval) * subsidy
 
 	// Blocks 0 and 1 have special subsidy amounts that have already been
 	// added above, so remove what their subsidies would have normally been
 	// which were also added above.
-	supply -= s.params.BaseSubsidy * 2
+	supply -= params.BaseSubsidy * 2
 
 	return dcrutil.Amount(supply)
 }
 
 // calcNextStakeDiffProposal7 returns the required stake difficulty (aka ticket
-// price) for the block after the current tip block the simulator is associated
-// with using the algorithm proposed by raedah, jy-p, and davecgh in
+// price) for the block after the tip of the passed chain view using the
+// algorithm proposed by raedah, jy-p, and davecgh in
 // https://github.com/decred/dcrd/issues/584
-func (s *simulator) calcNextStakeDiffProposal7() int64 {
+func calcNextStakeDiffProposal7(chain ChainView) int64 {
+	params, tip := chain.Params(), chain.Tip()
+
 	// Stake difficulty before any tickets could possibly be purchased is
 	// the minimum value.
 	nextHeight := int32(0)
-	if s.tip != nil {
-		nextHeight = s.tip.height + 1
+	if tip != nil {
+		nextHeight = tip.Height + 1
 	}
-	stakeDiffStartHeight := int32(s.params.CoinbaseMaturity) + 1
+	stakeDiffStartHeight := int32(params.CoinbaseMaturity) + 1
 	if nextHeight < stakeDiffStartHeight {
-		return s.params.MinimumStakeDiff
+		return params.MinimumStakeDiff
 	}
 
 	// Return the previous block's difficulty requirements if the next block
 	// is not at a difficulty retarget interval.
-	intervalSize := s.params.StakeDiffWindowSize
-	curDiff := s.tip.ticketPrice
+	intervalSize := params.StakeDiffWindowSize
+	curDiff := tip.TicketPrice
 	if int64(nextHeight)%intervalSize != 0 {
 		return curDiff
 	}
@@ -950,9 +1068,9 @@ func (s *simulator) calcNextStakeDiffProposal7() int64 {
 	// Attempt to get the pool size from the previous retarget interval.
 	var prevPoolSize int64
 	prevRetargetHeight := nextHeight - int32(intervalSize)
-	node := s.ancestorNode(s.tip, prevRetargetHeight, nil)
+	node := chain.Ancestor(prevRetargetHeight)
 	if node != nil {
-		prevPoolSize = int64(node.poolSize)
+		prevPoolSize = int64(node.PoolSize)
 	}
 
 	// Return the existing ticket price for the first few intervals.
@@ -961,22 +1079,20 @@ func (s *simulator) calcNextStakeDiffProposal7() int64 {
 	}
 
 	// Get the immature ticket count from the previous interval.
-	var prevImmatureTickets int64
-	ticketMaturity := int64(s.params.TicketMaturity)
-	s.ancestorNode(node, node.height-int32(ticketMaturity), func(n *blockNode) {
-		prevImmatureTickets += int64(len(n.ticketsAdded))
-	})
+	ticketMaturity := int64(params.TicketMaturity)
+	prevImmatureTickets := chain.NewTickets(node.Height-int32(ticketMaturity),
+		node.Height-1)
 
 	// Derive ratio of percent change in pool size.
-	immatureTickets := int64(len(s.immatureTickets))
-	curPoolSize := int64(s.tip.poolSize)
+	immatureTickets := int64(chain.ImmatureTickets())
+	curPoolSize := int64(tip.PoolSize)
 	curPoolSizeAll := curPoolSize + immatureTickets
 	prevPoolSizeAll := prevPoolSize + prevImmatureTickets
 	poolSizeChangeRatio := float64(curPoolSizeAll) / float64(prevPoolSizeAll)
 
 	// Derive ratio of percent of target pool size.
-	ticketsPerBlock := int64(s.params.TicketsPerBlock)
-	ticketPoolSize := int64(s.params.TicketPoolSize)
+	ticketsPerBlock := int64(params.TicketsPerBlock)
+	ticketPoolSize := int64(params.TicketPoolSize)
 	targetPoolSizeAll := ticketsPerBlock * (ticketPoolSize + ticketMaturity)
 	targetRatio := float64(curPoolSizeAll) / float64(targetPoolSizeAll)
 
@@ -985,13 +1101,13 @@ func (s *simulator) calcNextStakeDiffProposal7() int64 {
 
 	// Limit the new stake difficulty between the minimum allowed stake
 	// difficulty and a maximum value that is relative to the total supply.
-	estimatedSupply := s.estimateSupply(nextHeight)
+	estimatedSupply := chain.EstimatedSupply(nextHeight)
 	maximumStakeDiff := int64(float64(estimatedSupply) / float64(ticketPoolSize))
 	if nextDiff > maximumStakeDiff {
 		nextDiff = maximumStakeDiff
 	}
-	if nextDiff < s.params.MinimumStakeDiff {
-		nextDiff = s.params.MinimumStakeDiff
+	if nextDiff < params.MinimumStakeDiff {
+		nextDiff = params.MinimumStakeDiff
 	}
 	return nextDiff
 }
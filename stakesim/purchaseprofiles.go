@@ -0,0 +1,219 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// PurchaseProfile describes how the tickets demanded for a stake difficulty
+// window are distributed amongst the blocks within it along with details about
+// its behavior so the simulation results are self describing.
+type PurchaseProfile struct {
+	Model
+
+	// Calc returns how many of the provided number of tickets demanded for
+	// the window are purchased in the block at the provided position within
+	// the window.  Any tickets over the maximum allowed per block are
+	// carried over to the following block of the same window by the caller.
+	// The passed parameters contain a value for every parameter the profile
+	// defines.
+	Calc func(s *Simulator, params ModelParams, demandPerWindow, pos int32) int32
+}
+
+// purchaseProfiles houses all registered purchase profiles in the order
+// they were registered.
+var purchaseProfiles = newModelRegistry("purchase profile")
+
+// RegisterPurchaseProfile makes the provided purchase profile available to the
+// simulator under its key.  Much like RegisterDemandFunc, it is intended to be
+// called from the init function of the file that defines the profile.
+//
+// This function will panic if the key is empty or reserved, the calculation
+// function is nil, or a purchase profile with the same key has already been
+// registered since those are programming errors.
+func RegisterPurchaseProfile(pp *PurchaseProfile) {
+	purchaseProfiles.register(pp.Key, pp, pp.Calc != nil)
+}
+
+// LookupPurchaseProfile returns the registered purchase profile for the
+// provided key or nil when there is no such profile.
+func LookupPurchaseProfile(key string) *PurchaseProfile {
+	pp, _ := purchaseProfiles.lookup(key).(*PurchaseProfile)
+	return pp
+}
+
+// PurchaseProfileKeys returns the keys of all registered purchase profiles in
+// the order they were registered.
+func PurchaseProfileKeys() []string {
+	return purchaseProfiles.registeredKeys()
+}
+
+// PrintPurchaseProfiles prints all of the registered purchase profiles along
+// with their descriptions and tunable parameters to the passed writer.
+func PrintPurchaseProfiles(w io.Writer) {
+	purchaseProfiles.print(w, "Available purchase profiles:")
+}
+
+// windowPurchases returns the number of tickets purchased in the block at the
+// provided height given the number of tickets demanded for its window.  The
+// tickets are distributed amongst the blocks of the window by the purchase
+// profile and any that do not fit in a block due to the maximum number of new
+// tickets allowed per block are purchased in the following blocks.  Tickets
+// which still have not been purchased by the end of the window are dropped
+// since the ticket price changes.
+//
+// The simulated mempool decides which tickets are purchased at the provided
+// ticket price instead when it is enabled.
+func (s *Simulator) windowPurchases(nextHeight int32, ticketPrice int64, demandPerWindow int32) uint8 {
+	pos := nextHeight % int32(s.params.StakeDiffWindowSize)
+	if s.mempool != nil {
+		purchases := s.purchaseProfileFunc(demandPerWindow, pos)
+		return s.mempoolPurchases(nextHeight, ticketPrice, purchases)
+	}
+	if pos == 0 {
+		s.purchaseBacklog = 0
+	}
+
+	maxNewTickets := int32(s.params.MaxFreshStakePerBlock)
+	newTickets := s.purchaseProfileFunc(demandPerWindow, pos) +
+		s.purchaseBacklog
+	s.purchaseBacklog = 0
+	if newTickets > maxNewTickets {
+		s.purchaseBacklog = newTickets - maxNewTickets
+		newTickets = maxNewTickets
+	}
+	return uint8(newTickets)
+}
+
+// validateSlope returns an error when the slope parameter of the linear
+// purchase profiles is not in the range [0, 1] since the purchases would
+// otherwise be negative in part of the window.
+func validateSlope(params ModelParams) error {
+	return validateFractions(params, "slope")
+}
+
+// cumulativePurchases returns how many of the provided number of tickets
+// demanded for a window are purchased in the block at the provided position
+// within the window according to the passed cumulative distribution, which
+// maps the fraction of the window that has elapsed to the fraction of the
+// demand that has been purchased.  Rounding is done on the cumulative totals
+// so the entire demand is purchased by the end of the window.
+func (s *Simulator) cumulativePurchases(demandPerWindow, pos int32, cdf func(x float64) float64) int32 {
+	windowSize := float64(s.params.StakeDiffWindowSize)
+	demand := float64(demandPerWindow)
+	prevTotal := int32(demand * cdf(float64(pos)/windowSize))
+	total := int32(demand * cdf(float64(pos+1)/windowSize))
+	return total - prevTotal
+}
+
+// purchaseProfileEven spreads the purchases evenly across the window with any
+// remainder that does not divide evenly not purchased at all.  This matches
+// the behavior of older versions of the simulator.
+func purchaseProfileEven(s *Simulator, params ModelParams, demandPerWindow, pos int32) int32 {
+	return demandPerWindow / int32(s.params.StakeDiffWindowSize)
+}
+
+// purchaseProfileFrontLoaded models buyers rushing to purchase at the start of
+// the window when the price drops by linearly decreasing the purchases over
+// the window.
+func purchaseProfileFrontLoaded(s *Simulator, params ModelParams, demandPerWindow, pos int32) int32 {
+	slope := params["slope"]
+	return s.cumulativePurchases(demandPerWindow, pos, func(x float64) float64 {
+		return x + slope*(x-x*x)
+	})
+}
+
+// purchaseProfileBackLoaded models buyers holding back until the end of the
+// window by linearly increasing the purchases over the window.
+func purchaseProfileBackLoaded(s *Simulator, params ModelParams, demandPerWindow, pos int32) int32 {
+	slope := params["slope"]
+	return s.cumulativePurchases(demandPerWindow, pos, func(x float64) float64 {
+		return x - slope*(x-x*x)
+	})
+}
+
+// purchaseProfileExponential models the purchases at the start of the window
+// exponentially decaying as the eager buyers are satisfied.
+func purchaseProfileExponential(s *Simulator, params ModelParams, demandPerWindow, pos int32) int32 {
+	rate := params["rate"]
+	return s.cumulativePurchases(demandPerWindow, pos, func(x float64) float64 {
+		return (1 - math.Exp(-rate*x)) / (1 - math.Exp(-rate))
+	})
+}
+
+// purchaseProfileMempoolLimited models every buyer submitting their purchases
+// at the start of the window so they are only limited by the available block
+// space.
+func purchaseProfileMempoolLimited(s *Simulator, params ModelParams, demandPerWindow, pos int32) int32 {
+	if pos == 0 {
+		return demandPerWindow
+	}
+	return 0
+}
+
+func init() {
+	RegisterPurchaseProfile(&PurchaseProfile{
+		Model: Model{
+			Key:         "even",
+			Description: "Purchases are spread evenly across the window",
+		},
+		Calc: purchaseProfileEven,
+	})
+	RegisterPurchaseProfile(&PurchaseProfile{
+		Model: Model{
+			Key:         "frontloaded",
+			Description: "Purchases linearly decrease over the window",
+			Params: []ModelParam{{
+				Name:         "slope",
+				Description:  "Fraction the purchases at the start of the window exceed the average, in the range [0, 1]",
+				DefaultValue: 1,
+			}},
+			Validate: validateSlope,
+		},
+		Calc: purchaseProfileFrontLoaded,
+	})
+	RegisterPurchaseProfile(&PurchaseProfile{
+		Model: Model{
+			Key:         "backloaded",
+			Description: "Purchases linearly increase over the window",
+			Params: []ModelParam{{
+				Name:         "slope",
+				Description:  "Fraction the purchases at the end of the window exceed the average, in the range [0, 1]",
+				DefaultValue: 1,
+			}},
+			Validate: validateSlope,
+		},
+		Calc: purchaseProfileBackLoaded,
+	})
+	RegisterPurchaseProfile(&PurchaseProfile{
+		Model: Model{
+			Key:         "exponential",
+			Description: "Purchases exponentially decay over the window",
+			Params: []ModelParam{{
+				Name:         "rate",
+				Description:  "Decay rate of the purchases per window",
+				DefaultValue: 5,
+			}},
+			Validate: func(params ModelParams) error {
+				if rate := params["rate"]; rate <= 0 {
+					return fmt.Errorf("rate %v must be positive",
+						rate)
+				}
+				return nil
+			},
+		},
+		Calc: purchaseProfileExponential,
+	})
+	RegisterPurchaseProfile(&PurchaseProfile{
+		Model: Model{
+			Key:         "mempool",
+			Description: "All purchases are submitted at the start of the window and limited only by block space",
+		},
+		Calc: purchaseProfileMempoolLimited,
+	})
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"fmt"
+	"io"
+)
+
+// Model describes a model used by the simulator, such as a demand distribution
+// function or a missed vote model, along with its tunable parameters so the
+// simulation results are self describing.  It is embedded by every kind of
+// model, which adds the function that implements the model.
+type Model struct {
+	// Key is the unique identifier used to select the model.
+	Key string
+
+	// Description is a human-readable description of the behavior the
+	// model simulates.
+	Description string
+
+	// Params defines the tunable parameters the model accepts, if any.
+	Params []ModelParam
+
+	// Validate returns an error when the passed parameters are outside of
+	// the range the model supports.  It may be nil when any values are
+	// acceptable.
+	Validate func(params ModelParams) error
+
+	// kind is the human-readable kind of the model, such as "miss model",
+	// which is set when the model is registered.
+	kind string
+}
+
+// model returns the description of the model.  It allows the registries to
+// access the description of the models that embed it.
+func (m *Model) model() *Model {
+	return m
+}
+
+// WithOverrides returns the parameter values for the model with any of the
+// passed overrides that are parameters the model accepts applied to the
+// defaults.  Overrides for parameters the model does not accept are ignored.
+func (m *Model) WithOverrides(overrides ModelParams) ModelParams {
+	return WithModelParamOverrides(m.Params, overrides)
+}
+
+// Accepts returns whether or not the model accepts a parameter with the passed
+// name.
+func (m *Model) Accepts(name string) bool {
+	return AcceptsModelParam(m.Params, name)
+}
+
+// CheckOverrides returns an error if any of the passed overrides are for a
+// parameter the model does not accept.
+func (m *Model) CheckOverrides(overrides ModelParams) error {
+	for name := range overrides {
+		if !m.Accepts(name) {
+			return fmt.Errorf("%s %q does not accept a parameter "+
+				"named %q", m.kind, m.Key, name)
+		}
+	}
+	return nil
+}
+
+// ParamValues returns the parameter values for the model with the passed
+// overrides applied to the defaults just like WithOverrides.  An error is
+// returned when the resulting values are outside of the range the model
+// supports.
+func (m *Model) ParamValues(overrides ModelParams) (ModelParams, error) {
+	params := m.WithOverrides(overrides)
+	if m.Validate != nil {
+		if err := m.Validate(params); err != nil {
+			return nil, fmt.Errorf("invalid parameters for %s %q: "+
+				"%v", m.kind, m.Key, err)
+		}
+	}
+	return params, nil
+}
+
+// checkedParamValues returns the parameter values for the model just like
+// ParamValues after ensuring the model accepts all of the passed overrides.
+func (m *Model) checkedParamValues(overrides ModelParams) (ModelParams, error) {
+	if err := m.CheckOverrides(overrides); err != nil {
+		return nil, err
+	}
+	return m.ParamValues(overrides)
+}
+
+// describedModel is implemented by every kind of model that embeds Model.
+type describedModel interface {
+	model() *Model
+}
+
+// modelRegistry houses the registered models of a single kind, such as all of
+// the demand distribution functions, in the order they were registered along
+// with a lookup by their key.
+type modelRegistry struct {
+	kind    string
+	entries []interface{}
+	keys    []string
+	byKey   map[string]interface{}
+}
+
+// newModelRegistry returns an empty registry for the kind of model described
+// by the passed human-readable name.
+func newModelRegistry(kind string) *modelRegistry {
+	return &modelRegistry{
+		kind:  kind,
+		byKey: make(map[string]interface{}),
+	}
+}
+
+// register adds the provided model to the registry under the passed key.  The
+// implemented flag specifies whether or not the function that implements the
+// model is set.
+//
+// This function will panic if the key is empty or reserved, the model is not
+// implemented, or a model with the same key has already been registered since
+// those are programming errors.
+func (r *modelRegistry) register(key string, entry interface{}, implemented bool) {
+	if key == "" || key == "list" || !implemented {
+		panic(fmt.Sprintf("invalid %s registration %q", r.kind, key))
+	}
+	if _, ok := r.byKey[key]; ok {
+		panic(fmt.Sprintf("%s %q is already registered", r.kind, key))
+	}
+	if dm, ok := entry.(describedModel); ok {
+		dm.model().kind = r.kind
+	}
+
+	r.entries = append(r.entries, entry)
+	r.keys = append(r.keys, key)
+	r.byKey[key] = entry
+}
+
+// lookup returns the registered model for the provided key or nil when there
+// is no such model.
+func (r *modelRegistry) lookup(key string) interface{} {
+	return r.byKey[key]
+}
+
+// registeredKeys returns the keys of all registered models in the order they
+// were registered.
+func (r *modelRegistry) registeredKeys() []string {
+	return append([]string(nil), r.keys...)
+}
+
+// print prints the passed title followed by all of the registered models along
+// with their descriptions and tunable parameters to the passed writer.
+func (r *modelRegistry) print(w io.Writer, title string) {
+	fmt.Fprintln(w, title)
+	for _, entry := range r.entries {
+		m := entry.(describedModel).model()
+		fmt.Fprintf(w, "\n  %s - %s\n", m.Key, m.Description)
+		PrintModelParams(w, m.Params)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"io"
+	"math"
+)
+
+// neverRevoked is the revocation height of tickets that are never revoked.
+const neverRevoked = math.MaxInt32
+
+// RevocationModel describes a model which determines when the owners of missed
+// and expired tickets revoke them along with details about its behavior so the
+// simulation results are self describing.
+type RevocationModel struct {
+	Model
+
+	// Calc returns the height at which the provided ticket, which became
+	// eligible to be revoked at the provided height, is revoked.  It must
+	// be after the provided height or neverRevoked.  The passed parameters
+	// contain a value for every parameter the model defines.
+	Calc func(s *Simulator, params ModelParams, ticket *StakeTicket, height int32) int32
+}
+
+// revocationModels houses all registered revocation models in the
+// order they were registered.
+var revocationModels = newModelRegistry("revocation model")
+
+// RegisterRevocationModel makes the provided revocation model available to the
+// simulator under its key.  Much like RegisterDemandFunc, it is intended to be
+// called from the init function of the file that defines the model.
+//
+// This function will panic if the key is empty or reserved, the calculation
+// function is nil, or a revocation model with the same key has already been
+// registered since those are programming errors.
+func RegisterRevocationModel(rm *RevocationModel) {
+	revocationModels.register(rm.Key, rm, rm.Calc != nil)
+}
+
+// LookupRevocationModel returns the registered revocation model for the
+// provided key or nil when there is no such model.
+func LookupRevocationModel(key string) *RevocationModel {
+	rm, _ := revocationModels.lookup(key).(*RevocationModel)
+	return rm
+}
+
+// RevocationModelKeys returns the keys of all registered revocation models in
+// the order they were registered.
+func RevocationModelKeys() []string {
+	return revocationModels.registeredKeys()
+}
+
+// PrintRevocationModels prints all of the registered revocation models along
+// with their descriptions and tunable parameters to the passed writer.
+func PrintRevocationModels(w io.Writer) {
+	revocationModels.print(w, "Available revocation models:")
+}
+
+// revocationModelImmediate revokes every ticket in the block after it becomes
+// eligible to be revoked.  This matches the behavior of older versions of the
+// simulator.
+func revocationModelImmediate(s *Simulator, params ModelParams, ticket *StakeTicket, height int32) int32 {
+	return height + 1
+}
+
+// revocationModelDelayed revokes tickets after a random delay with an
+// exponential distribution, which models wallets that are only occasionally
+// online to notice their tickets need to be revoked, and never revokes a
+// fraction of them at all, which models lost or abandoned wallets.
+func revocationModelDelayed(s *Simulator, params ModelParams, ticket *StakeTicket, height int32) int32 {
+	if s.rng.Float64() < params["neverfraction"] {
+		return neverRevoked
+	}
+
+	delay := params["mindelay"] + s.rng.ExpFloat64()*params["meandelay"]
+	if delay < 1 {
+		delay = 1
+	}
+	if delay >= float64(neverRevoked-height) {
+		return neverRevoked
+	}
+	return height + int32(delay)
+}
+
+func init() {
+	RegisterRevocationModel(&RevocationModel{
+		Model: Model{
+			Key:         "immediate",
+			Description: "Every ticket is revoked as soon as possible",
+		},
+		Calc: revocationModelImmediate,
+	})
+	RegisterRevocationModel(&RevocationModel{
+		Model: Model{
+			Key:         "delayed",
+			Description: "Tickets are revoked after a random delay and some are never revoked",
+			Params: []ModelParam{{
+				Name:         "mindelay",
+				Description:  "Minimum number of blocks before a ticket is revoked",
+				DefaultValue: 1,
+			}, {
+				Name:         "meandelay",
+				Description:  "Mean number of blocks of exponentially distributed delay in addition to the minimum",
+				DefaultValue: 288,
+			}, {
+				Name:         "neverfraction",
+				Description:  "Fraction of tickets which are never revoked",
+				DefaultValue: 0.05,
+			}},
+			Validate: func(params ModelParams) error {
+				err := validateFractions(params, "neverfraction")
+				if err != nil {
+					return err
+				}
+				return validateAtLeast(params, 0, "mindelay",
+					"meandelay")
+			},
+		},
+		Calc: revocationModelDelayed,
+	})
+}
@@ -0,0 +1,328 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/dcrutil"
+)
+
+const (
+	// fieldsPerRecord defines the number of fields expected in each line
+	// of the input CSV data.
+	fieldsPerRecord = 3
+)
+
+// convertRecord converts the passed record, which is expected to be parsed from
+// a CSV file, and thus will be a slice of strings, into a struct with concrete
+// types.  The decoded block header is also returned.
+func convertRecord(record []string) (*SimData, *wire.BlockHeader, error) {
+	headerBytes, err := hex.DecodeString(record[1])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var header wire.BlockHeader
+	if err := header.FromBytes(headerBytes); err != nil {
+		return nil, nil, err
+	}
+	var hashStrings []string
+	if record[2] != "" {
+		hashStrings = strings.Split(record[2], ":")
+	}
+	if len(hashStrings) != int(header.FreshStake) {
+		return nil, nil, fmt.Errorf("%d ticket hashes in CSV for %d new tickets",
+			len(hashStrings), header.FreshStake)
+	}
+	ticketHashes := make([]chainhash.Hash, 0, len(hashStrings))
+	for _, hashString := range hashStrings {
+		hash, err := chainhash.NewHashFromStr(hashString)
+		if err != nil {
+			return nil, nil, err
+		}
+		ticketHashes = append(ticketHashes, *hash)
+	}
+
+	return &SimData{
+		Header:       headerBytes,
+		Voters:       header.Voters,
+		PrevValid:    dcrutil.IsFlagSet16(header.VoteBits, dcrutil.BlockValid),
+		NewTickets:   header.FreshStake,
+		TicketHashes: ticketHashes,
+		Revocations:  uint16(header.Revocations),
+	}, &header, nil
+}
+
+// checkDemand returns an ErrDemandOutOfRange error when the passed demand for
+// the window that starts at the provided height is not in the range [0, 1],
+// including when it is NaN.  The passed prefix describes what produced the
+// demand in the error.
+func checkDemand(nextHeight int32, demand float64, prefix string) error {
+	if demand >= 0 && demand <= 1 {
+		return nil
+	}
+
+	var limit float64
+	if demand > 1 {
+		limit = 1
+	}
+	str := fmt.Sprintf("%s a demand of %v at height %d which is not in "+
+		"the range of [0, 1]", prefix, demand, nextHeight)
+	return newSimError(ErrDemandOutOfRange, nextHeight, demand, limit, str)
+}
+
+// reportProgress periodically writes the current simulator height to the
+// progress writer when progress reporting is enabled.
+func (s *Simulator) reportProgress() {
+	if s.progress == nil {
+		return
+	}
+	if s.tip.height%10000 == 0 && s.tip.height != 0 {
+		fmt.Fprintln(s.progress)
+	}
+	if s.tip.height%1000 == 0 && s.tip.height != 0 {
+		fmt.Fprintf(s.progress, "..%d", s.tip.height)
+	}
+}
+
+// SimulateFromCSV runs the simulation using input data from a CSV file.  It is
+// realistically only intended to be used with data extracted from mainnet in
+// order to exactly replicate its live ticket pool.
+//
+// The ticket price and pool size of every block are also checked against the
+// values committed to by its header when verification is enabled.
+func (s *Simulator) SimulateFromCSV(csvPath string) error {
+	// Open the simulation CSV data which is expected to be in the following
+	// format:
+	//
+	// Block Header,Winning Ticket Hashes
+	csvFile, err := os.Open(csvPath)
+	if err != nil {
+		return err
+	}
+
+	// Create a new simulator using input from the CSV file.
+	r := csv.NewReader(csvFile)
+	r.FieldsPerRecord = fieldsPerRecord
+	var handledHeader bool
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		// Skip header fields if they exist.
+		if !handledHeader {
+			handledHeader = true
+			_, err := strconv.Atoi(record[0])
+			if err != nil {
+				continue
+			}
+		}
+
+		// Convert the CSV to concrete data.
+		data, header, err := convertRecord(record)
+		if err != nil {
+			return err
+		}
+
+		// The revocations in real block headers include those that are
+		// performed automatically once automatic revocations are
+		// active, while the simulation data only counts revocations of
+		// previously unrevoked tickets.
+		numAutoRevocations, err := s.numAutoRevocations(data.Voters)
+		if err != nil {
+			return err
+		}
+		if numAutoRevocations > data.Revocations {
+			return fmt.Errorf("block after height %d has %d "+
+				"revocations which is less than the %d automatic "+
+				"revocations", s.tip.height, data.Revocations,
+				numAutoRevocations)
+		}
+		data.Revocations -= numAutoRevocations
+
+		// Create a new node that extends the current tip using the
+		// simulation data and potentially report the progress.
+		node, err := s.NextNode(data)
+		if err != nil {
+			return err
+		}
+		if s.verify != nil {
+			s.verify.verifyNode(node, header)
+		}
+		s.reportProgress()
+	}
+
+	return nil
+}
+
+// Simulate runs the simulation using a calculated demand curve which models
+// how ticket purchasing would typically proceed based upon the price and the
+// VWAP.
+//
+// The heights of events that are relative to the number of simulated blocks
+// are resolved against the number of blocks passed to the first call, so a
+// simulation that is continued by later calls, including from a restored
+// snapshot, keeps the same event heights.
+func (s *Simulator) Simulate(numBlocks uint64) error {
+	// Shorter versions of some params for convenience.
+	ticketsPerBlock := s.params.TicketsPerBlock
+	stakeValidationHeight := int32(s.params.StakeValidationHeight)
+	stakeDiffWindowSize := int32(s.params.StakeDiffWindowSize)
+	maxNewTicketsPerBlock := int32(s.params.MaxFreshStakePerBlock)
+	maxTicketsPerWindow := maxNewTicketsPerBlock * stakeDiffWindowSize
+
+	// Resolve the heights of any events that change ticket purchasing
+	// behavior relative to the total number of blocks when they have not
+	// already been resolved by an earlier call.
+	if err := s.scheduleEvents(numBlocks); err != nil {
+		return err
+	}
+
+	demandPerWindow := maxTicketsPerWindow
+	for i := uint64(0); i < numBlocks; i++ {
+		var nextHeight int32
+		var totalSupply, spendableSupply, stakedCoins dcrutil.Amount
+		if s.tip != nil {
+			nextHeight = s.tip.height + 1
+			totalSupply = s.tip.totalSupply
+			spendableSupply = s.tip.spendableSupply
+			stakedCoins = s.tip.stakedCoins
+		}
+
+		// Purchase tickets according to simulated demand curve.
+		nextTicketPrice := s.nextTicketPrice()
+		if nextTicketPrice < s.params.MinimumStakeDiff {
+			str := fmt.Sprintf("Ticket price function returned a "+
+				"price of %v at height %d which is under the "+
+				"minimum allowed price of %v",
+				dcrutil.Amount(nextTicketPrice), nextHeight,
+				dcrutil.Amount(s.params.MinimumStakeDiff))
+			return newSimError(ErrPriceTooLow, nextHeight,
+				float64(nextTicketPrice),
+				float64(s.params.MinimumStakeDiff), str)
+		}
+
+		// Calculate the demand for each window unless the stakeholder
+		// agents decide the purchases instead.
+		if s.agents == nil && nextHeight%stakeDiffWindowSize == 0 &&
+			nextHeight != 0 {
+
+			demand := s.demandFunc(nextHeight, nextTicketPrice)
+			err := checkDemand(nextHeight, demand,
+				"Demand function returned")
+			if err != nil {
+				return err
+			}
+			demand = s.noiseFunc(demand)
+			demand = s.applyDemandEvents(nextHeight, demand)
+			err = checkDemand(nextHeight, demand,
+				"Noise and events produced")
+			if err != nil {
+				return err
+			}
+			demandPerWindow = int32(float64(maxTicketsPerWindow) * demand)
+		}
+
+		// Limit the total staked coins to the stake cap, which defaults
+		// to 40% of the total supply unless changed by an event, and
+		// stop purchasing altogether while purchases are frozen.
+		stakeCap := s.activeStakeCap(nextHeight)
+		maxStaked := dcrutil.Amount(float64(totalSupply) * stakeCap)
+		canPurchase := stakedCoins <= maxStaked
+		if s.isFrozen(nextHeight, stakedCoins) {
+			canPurchase = false
+		}
+
+		// Purchase the tickets decided by the stakeholder agents when
+		// they are enabled or otherwise distribute the demand for the
+		// window across its blocks according to the purchase profile.
+		// The purchases are added to the simulated mempool when it is
+		// enabled, and those already waiting in it are still included
+		// even when no new purchases are made.
+		var newTickets uint8
+		switch {
+		case !canPurchase && s.mempool != nil:
+			newTickets = s.mempoolPurchases(nextHeight,
+				nextTicketPrice, 0)
+		case !canPurchase:
+		case s.agents != nil:
+			newTickets = s.agentPurchases(nextHeight,
+				nextTicketPrice, spendableSupply)
+			if s.mempool != nil {
+				newTickets = s.mempoolPurchases(nextHeight,
+					nextTicketPrice, int32(newTickets))
+			}
+		default:
+			newTickets = s.windowPurchases(nextHeight,
+				nextTicketPrice, demandPerWindow)
+		}
+		maxPossible := int64(spendableSupply) / nextTicketPrice
+		if int64(newTickets) > maxPossible {
+			newTickets = uint8(maxPossible)
+		}
+
+		// Start voting once stake validation height is reached with
+		// the number of missed votes determined by the miss model, or
+		// by the uptime of the VSPs for the tickets delegated to them
+		// when they are simulated.  Since a block requires a majority
+		// of the votes to be valid, the number of missed votes is
+		// limited accordingly.  Missed and expired tickets are revoked
+		// according to the revocation model.
+		var numVotes uint16
+		var missedTickets map[chainhash.Hash]struct{}
+		if nextHeight >= stakeValidationHeight {
+			minVotes := ticketsPerBlock/2 + 1
+			var misses uint16
+			if len(s.vsps) > 0 {
+				var err error
+				missedTickets, err = s.votingServiceMisses(
+					nextHeight, ticketsPerBlock-minVotes)
+				if err != nil {
+					return err
+				}
+				misses = uint16(len(missedTickets))
+			} else {
+				misses = s.missFunc(nextHeight, ticketsPerBlock)
+			}
+			numVotes = ticketsPerBlock - misses
+			if misses > ticketsPerBlock || numVotes < minVotes {
+				numVotes = minVotes
+			}
+		}
+		data := &SimData{
+			NewTickets:    newTickets,
+			PrevValid:     true,
+			Revocations:   s.numRevocations(nextHeight),
+			Voters:        numVotes,
+			MissedTickets: missedTickets,
+		}
+
+		// Create a new node that extends the current tip using the
+		// simulation data and potentially report the progress.
+		if _, err := s.NextNode(data); err != nil {
+			return err
+		}
+		if s.mempool != nil {
+			s.tip.mempoolDepth = uint32(s.mempool.depth())
+		}
+		s.reportProgress()
+	}
+
+	return nil
+}
@@ -0,0 +1,277 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"math/rand"
+
+	"github.com/davecgh/dcrstakesim/internal/tickettreap"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrutil"
+)
+
+// countingSource is a source of pseudorandom numbers which keeps track of how
+// many values have been drawn from it so its state can be snapshotted and later
+// restored by replaying the same number of draws from the same seed.  It
+// produces the same values as the source it wraps.
+type countingSource struct {
+	src   rand.Source64
+	seed  int64
+	draws uint64
+}
+
+// newCountingSource returns a new counting source seeded with the provided
+// value.
+func newCountingSource(seed int64) *countingSource {
+	return &countingSource{
+		src:  rand.NewSource(seed).(rand.Source64),
+		seed: seed,
+	}
+}
+
+// Int63 returns a non-negative pseudorandom 63-bit integer.  It is part of the
+// rand.Source interface implementation.
+func (cs *countingSource) Int63() int64 {
+	cs.draws++
+	return cs.src.Int63()
+}
+
+// Uint64 returns a pseudorandom 64-bit integer.  It is part of the
+// rand.Source64 interface implementation.
+func (cs *countingSource) Uint64() uint64 {
+	cs.draws++
+	return cs.src.Uint64()
+}
+
+// Seed reseeds the source with the provided value.  It is part of the
+// rand.Source interface implementation.
+func (cs *countingSource) Seed(seed int64) {
+	cs.src.Seed(seed)
+	cs.seed = seed
+	cs.draws = 0
+}
+
+// restore returns the source to the state it had after the provided number of
+// draws since it was last seeded.
+func (cs *countingSource) restore(seed int64, draws uint64) {
+	cs.Seed(seed)
+	for cs.draws < draws {
+		cs.Uint64()
+	}
+}
+
+// Snapshot houses the state of a simulator as of a given tip.  It is created
+// with Simulator.Snapshot and allows the simulator to be returned to that state
+// with Simulator.Restore, such as to simulate multiple alternative futures from
+// a common chain.
+type Snapshot struct {
+	sim *Simulator
+
+	root *BlockNode
+	tip  *BlockNode
+
+	// rngSeed and rngDraws are the state of the source of randomness for
+	// the behavioral models.
+	rngSeed  int64
+	rngDraws uint64
+
+	noiseState      float64
+	outageEndHeight int32
+	purchaseBacklog int32
+	scheduledEvents []*ScheduledEvent
+	freezeTargets   map[*ScheduledEvent]dcrutil.Amount
+
+	// These fields house copies of the ticket pools.  Since tickets that
+	// are still immature, live, or unrevoked are modified as they progress
+	// through the pools, tickets holds those tickets and ticketValues
+	// their values as of the snapshot.
+	immatureTickets  []*StakeTicket
+	liveTickets      *tickettreap.Immutable
+	expireHeights    map[int32][]*StakeTicket
+	expiredTickets   []*StakeTicket
+	missedTickets    []*StakeTicket
+	unrevokedTickets []*StakeTicket
+	wonTickets       []*StakeTicket
+	tickets          []*StakeTicket
+	ticketValues     []StakeTicket
+
+	maturingSupply map[int32]dcrutil.Amount
+	ticketVSPs     map[chainhash.Hash]*VotingService
+	mempool        *ticketMempool
+	agents         *agentPopulation
+	verify         *VerifySummary
+
+	// algorithmState is the state of the ticket price algorithm when it
+	// is a StatefulStakeDiffAlgorithm.
+	algorithmState interface{}
+}
+
+// Height returns the height of the tip as of the snapshot or -1 when the chain
+// did not have any blocks yet.
+func (snap *Snapshot) Height() int32 {
+	if snap.tip == nil {
+		return -1
+	}
+	return snap.tip.height
+}
+
+// fixedLen returns the passed slice with its capacity limited to its length so
+// that appending to it always allocates a new backing array.  This allows the
+// slices of tickets that are only ever appended to be shared with snapshots.
+func fixedLen(tickets []*StakeTicket) []*StakeTicket {
+	return tickets[:len(tickets):len(tickets)]
+}
+
+// copyTickets returns a copy of the passed slice of tickets.  This is used for
+// the slices of tickets that are modified in place.
+func copyTickets(tickets []*StakeTicket) []*StakeTicket {
+	return append([]*StakeTicket(nil), tickets...)
+}
+
+// copySupply returns a copy of the passed amounts of coin supply keyed by the
+// height at which they mature.
+func copySupply(maturingSupply map[int32]dcrutil.Amount) map[int32]dcrutil.Amount {
+	clone := make(map[int32]dcrutil.Amount, len(maturingSupply))
+	for height, amount := range maturingSupply {
+		clone[height] = amount
+	}
+	return clone
+}
+
+// copyFreezeTargets returns a copy of the passed amounts of staked coins
+// purchases are frozen until keyed by their freeze event.  It returns nil when
+// the events have not been scheduled yet.
+func copyFreezeTargets(freezeTargets map[*ScheduledEvent]dcrutil.Amount) map[*ScheduledEvent]dcrutil.Amount {
+	if freezeTargets == nil {
+		return nil
+	}
+	clone := make(map[*ScheduledEvent]dcrutil.Amount, len(freezeTargets))
+	for event, target := range freezeTargets {
+		clone[event] = target
+	}
+	return clone
+}
+
+// Snapshot returns the current state of the simulator, including the state of
+// its ticket price algorithm when it is a StatefulStakeDiffAlgorithm, so the
+// simulator can later be returned to it with Restore.  The chain is shared with
+// the snapshot since blocks are not modified once they are connected.
+func (s *Simulator) Snapshot() *Snapshot {
+	snap := &Snapshot{
+		sim:              s,
+		root:             s.root,
+		tip:              s.tip,
+		rngSeed:          s.rngSource.seed,
+		rngDraws:         s.rngSource.draws,
+		noiseState:       s.noiseState,
+		outageEndHeight:  s.outageEndHeight,
+		purchaseBacklog:  s.purchaseBacklog,
+		scheduledEvents:  s.scheduledEvents,
+		immatureTickets:  copyTickets(s.immatureTickets),
+		liveTickets:      s.liveTickets,
+		expiredTickets:   fixedLen(s.expiredTickets),
+		missedTickets:    fixedLen(s.missedTickets),
+		unrevokedTickets: fixedLen(s.unrevokedTickets),
+		wonTickets:       fixedLen(s.wonTickets),
+		maturingSupply:   copySupply(s.maturingSupply),
+		freezeTargets:    copyFreezeTargets(s.freezeTargets),
+	}
+	snap.expireHeights = make(map[int32][]*StakeTicket,
+		len(s.expireHeights))
+	for height, tickets := range s.expireHeights {
+		snap.expireHeights[height] = tickets
+		snap.tickets = append(snap.tickets, tickets...)
+	}
+	snap.tickets = append(snap.tickets, s.unrevokedTickets...)
+	snap.ticketValues = make([]StakeTicket, 0, len(snap.tickets))
+	for _, ticket := range snap.tickets {
+		snap.ticketValues = append(snap.ticketValues, *ticket)
+	}
+	if s.ticketVSPs != nil {
+		snap.ticketVSPs = make(map[chainhash.Hash]*VotingService,
+			len(s.ticketVSPs))
+		for hash, vsp := range s.ticketVSPs {
+			snap.ticketVSPs[hash] = vsp
+		}
+	}
+	if s.mempool != nil {
+		snap.mempool = s.mempool.clone()
+	}
+	if s.agents != nil {
+		snap.agents = s.agents.clone()
+	}
+	if s.verify != nil {
+		verify := *s.verify
+		snap.verify = &verify
+	}
+	if alg, ok := s.stakeDiffAlgorithm.(StatefulStakeDiffAlgorithm); ok {
+		snap.algorithmState = alg.Snapshot()
+	}
+	return snap
+}
+
+// Restore returns the simulator to the state of the passed snapshot, which
+// must have been created by the same simulator, discarding any blocks that were
+// connected after it.  The snapshot remains valid, so the simulator may be
+// restored to it multiple times.
+//
+// This function will panic if the snapshot was created by a different
+// simulator since that is a programming error.
+func (s *Simulator) Restore(snap *Snapshot) {
+	if snap.sim != s {
+		panic("snapshot was created by a different simulator")
+	}
+
+	s.root = snap.root
+	s.tip = snap.tip
+	if s.tip != nil {
+		s.tip.next = nil
+	}
+	s.rngSource.restore(snap.rngSeed, snap.rngDraws)
+	s.noiseState = snap.noiseState
+	s.outageEndHeight = snap.outageEndHeight
+	s.purchaseBacklog = snap.purchaseBacklog
+	s.scheduledEvents = snap.scheduledEvents
+	s.freezeTargets = copyFreezeTargets(snap.freezeTargets)
+
+	for i, ticket := range snap.tickets {
+		*ticket = snap.ticketValues[i]
+	}
+	s.immatureTickets = copyTickets(snap.immatureTickets)
+	s.liveTickets = snap.liveTickets
+	s.expireHeights = make(map[int32][]*StakeTicket,
+		len(snap.expireHeights))
+	for height, tickets := range snap.expireHeights {
+		s.expireHeights[height] = tickets
+	}
+	s.expiredTickets = snap.expiredTickets
+	s.missedTickets = snap.missedTickets
+	s.unrevokedTickets = snap.unrevokedTickets
+	s.wonTickets = snap.wonTickets
+
+	s.maturingSupply = copySupply(snap.maturingSupply)
+	if snap.ticketVSPs != nil {
+		s.ticketVSPs = make(map[chainhash.Hash]*VotingService,
+			len(snap.ticketVSPs))
+		for hash, vsp := range snap.ticketVSPs {
+			s.ticketVSPs[hash] = vsp
+		}
+	}
+	if snap.mempool != nil {
+		s.mempool = snap.mempool.clone()
+	}
+	if snap.agents != nil {
+		s.agents = snap.agents.clone()
+	}
+	if snap.verify != nil {
+		verify := *snap.verify
+		s.verify = &verify
+	}
+	if alg, ok := s.stakeDiffAlgorithm.(StatefulStakeDiffAlgorithm); ok {
+		if snap.algorithmState != nil {
+			alg.Restore(snap.algorithmState)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/decred/dcrd/chaincfg"
+)
+
+// TestCountingSourceRestore ensures restoring a counting source produces the
+// same values as the source it wraps after the same number of draws.
+func TestCountingSourceRestore(t *testing.T) {
+	const seed = 7
+	const numDraws = 1000
+
+	cs := newCountingSource(seed)
+	rng := rand.New(cs)
+	for i := 0; i < numDraws; i++ {
+		rng.Float64()
+	}
+	draws := cs.draws
+	want := make([]int64, 10)
+	for i := range want {
+		want[i] = rng.Int63()
+	}
+
+	cs.restore(seed, draws)
+	for i := range want {
+		if got := rng.Int63(); got != want[i] {
+			t.Fatalf("draw %d after restore: got %d, want %d", i, got,
+				want[i])
+		}
+	}
+
+	// The values must also match those of an unwrapped source.
+	ref := rand.New(rand.NewSource(seed))
+	for i := uint64(0); i < draws; i++ {
+		ref.Int63()
+	}
+	for i := range want {
+		if got := ref.Int63(); got != want[i] {
+			t.Fatalf("draw %d of unwrapped source: got %d, want %d",
+				i, got, want[i])
+		}
+	}
+}
+
+// newSnapshotTestSim returns a simulator which exercises every model that
+// keeps state across blocks along with events which are relative to the number
+// of simulated blocks and a freeze event.
+func newSnapshotTestSim(t *testing.T) *Simulator {
+	events, err := ParseEvents("demand:60%-80%:2,freeze:2100-2400:0.1")
+	if err != nil {
+		t.Fatalf("unable to parse events: %v", err)
+	}
+	vsps, err := ParseVotingServices("0.3:0.02:0.9")
+	if err != nil {
+		t.Fatalf("unable to parse voting services: %v", err)
+	}
+	sim, err := New(&Config{
+		Params:          &chaincfg.TestNet2Params,
+		Seed:            7,
+		Events:          events,
+		PriceFunc:       LookupPriceFunc("5"),
+		MissModel:       LookupMissModel("outage"),
+		RevocationModel: LookupRevocationModel("delayed"),
+		NoiseModel:      LookupNoiseModel("meanreverting"),
+		Mempool:         true,
+		NumAgents:       100,
+		SplitTickets:    true,
+		VSPs:            vsps,
+	})
+	if err != nil {
+		t.Fatalf("unable to create simulator: %v", err)
+	}
+	return sim
+}
+
+// simTrace returns a description of every block of the simulator after the
+// provided height.
+func simTrace(sim *Simulator, fromHeight int32) []string {
+	var trace []string
+	for node := sim.Root(); node != nil; node = node.Next() {
+		if node.Height() <= fromHeight {
+			continue
+		}
+		trace = append(trace, fmt.Sprint(node.Height(),
+			node.TicketPrice(), node.PoolSize(), node.TotalSupply(),
+			node.NumVoters(), len(node.TicketsAdded()),
+			len(node.TicketsRevoked())))
+	}
+	return trace
+}
+
+// compareTraces reports the first block that differs between the passed
+// traces.
+func compareTraces(t *testing.T, name string, got, want []string) {
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d blocks, want %d", name, len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%s: block %d differs: got %q, want %q", name,
+				i, got[i], want[i])
+		}
+	}
+}
+
+// TestSnapshotRestoreForks ensures simulating multiple forks from a restored
+// snapshot produces identical chains that also match simulating the same
+// number of blocks without a snapshot.
+func TestSnapshotRestoreForks(t *testing.T) {
+	const snapHeight = 1999
+
+	sim := newSnapshotTestSim(t)
+	if err := sim.Simulate(snapHeight + 1); err != nil {
+		t.Fatalf("unable to simulate: %v", err)
+	}
+	snap := sim.Snapshot()
+	if snap.Height() != snapHeight {
+		t.Fatalf("snapshot height: got %d, want %d", snap.Height(),
+			snapHeight)
+	}
+
+	if err := sim.Simulate(600); err != nil {
+		t.Fatalf("unable to simulate first fork: %v", err)
+	}
+	first := simTrace(sim, snapHeight)
+
+	if len(sim.freezeTargets) != 1 {
+		t.Fatalf("got %d freeze targets after first fork, want 1",
+			len(sim.freezeTargets))
+	}
+
+	// The freeze event starts after the snapshot, so its target must not
+	// survive restoring the snapshot.
+	sim.Restore(snap)
+	if sim.Tip().Height() != snapHeight {
+		t.Fatalf("restored height: got %d, want %d",
+			sim.Tip().Height(), snapHeight)
+	}
+	if len(sim.freezeTargets) != 0 {
+		t.Fatalf("got %d freeze targets after restore, want 0",
+			len(sim.freezeTargets))
+	}
+	if err := sim.Simulate(600); err != nil {
+		t.Fatalf("unable to simulate second fork: %v", err)
+	}
+	second := simTrace(sim, snapHeight)
+	compareTraces(t, "second fork", second, first)
+
+	ref := newSnapshotTestSim(t)
+	if err := ref.Simulate(snapHeight + 1); err != nil {
+		t.Fatalf("unable to simulate: %v", err)
+	}
+	if err := ref.Simulate(600); err != nil {
+		t.Fatalf("unable to simulate: %v", err)
+	}
+	compareTraces(t, "without snapshot", first, simTrace(ref, snapHeight))
+}
+
+// TestSimulateResolvesEventsOnce ensures the heights of events which are
+// relative to the number of simulated blocks are resolved by the first call to
+// Simulate and are not changed by later calls.
+func TestSimulateResolvesEventsOnce(t *testing.T) {
+	sim := newSnapshotTestSim(t)
+	if err := sim.Simulate(2000); err != nil {
+		t.Fatalf("unable to simulate: %v", err)
+	}
+	snap := sim.Snapshot()
+	if err := sim.Simulate(300); err != nil {
+		t.Fatalf("unable to simulate: %v", err)
+	}
+	sim.Restore(snap)
+	if err := sim.Simulate(300); err != nil {
+		t.Fatalf("unable to simulate: %v", err)
+	}
+
+	want := []struct {
+		start, end int32
+	}{
+		{start: 1200, end: 1600},
+		{start: 2100, end: 2400},
+	}
+	events := sim.ScheduledEvents()
+	if len(events) != len(want) {
+		t.Fatalf("got %d scheduled events, want %d", len(events),
+			len(want))
+	}
+	for i, event := range events {
+		if event.StartHeight != want[i].start ||
+			event.EndHeight != want[i].end {
+
+			t.Errorf("event %q: got heights %d-%d, want %d-%d",
+				event.Event, event.StartHeight, event.EndHeight,
+				want[i].start, want[i].end)
+		}
+	}
+}
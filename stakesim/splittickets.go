@@ -0,0 +1,109 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import "fmt"
+
+// SplitParams are the tunable parameters of the split tickets stakeholder
+// agents that cannot afford a full ticket use to purchase tickets jointly.
+var SplitParams = []ModelParam{{
+	Name:         "contribution",
+	Description:  "Maximum fraction of its balance a stakeholder contributes to a split ticket",
+	DefaultValue: 0.5,
+}, {
+	Name:         "mincontribution",
+	Description:  "Minimum contribution to a split ticket as a fraction of the ticket price",
+	DefaultValue: 0.01,
+}, {
+	Name:         "maxparticipants",
+	Description:  "Maximum number of stakeholders that share a split ticket",
+	DefaultValue: 64,
+}}
+
+// ValidateSplitParams returns an error if any of the passed split ticket
+// parameters are outside of their valid range.
+func ValidateSplitParams(params ModelParams) error {
+	switch {
+	case params["contribution"] <= 0 || params["contribution"] > 1:
+		return fmt.Errorf("split ticket parameter \"contribution\" " +
+			"must be in the range (0, 1]")
+	case params["mincontribution"] < 0 || params["mincontribution"] > 1:
+		return fmt.Errorf("split ticket parameter \"mincontribution\" " +
+			"must be in the range [0, 1]")
+	case params["maxparticipants"] < 2:
+		return fmt.Errorf("split ticket parameter \"maxparticipants\" " +
+			"must be at least 2")
+	}
+	return nil
+}
+
+// splitTicketPurchase either adds the passed stakeholder, which cannot afford a
+// full ticket at the provided ticket price, to the current split session with
+// the given portion of its balance it is willing to spend or marks it as priced
+// out when split tickets are disabled or the contribution is under the
+// minimum.  It returns whether or not the contribution completed a split
+// ticket.
+func (s *Simulator) splitTicketPurchase(sh *stakeholder, willing float64, ticketPrice int64) bool {
+	params := s.agents.splitParams
+	if params == nil {
+		sh.pricedOut++
+		return false
+	}
+	contribution := willing * params["contribution"]
+	if contribution < params["mincontribution"]*float64(ticketPrice) {
+		sh.pricedOut++
+		return false
+	}
+	purchased := s.agents.session.join(sh, contribution, ticketPrice,
+		int(params["maxparticipants"]))
+	if purchased {
+		s.agents.numSplitTickets++
+	}
+	return purchased
+}
+
+// splitSession houses the stakeholders that are pooling their contributions
+// into a split ticket along with the total amount contributed so far.
+type splitSession struct {
+	participants []*stakeholder
+	total        float64
+}
+
+// join adds the passed stakeholder to the split session with the provided
+// contribution and returns whether or not the contributions now cover the
+// provided ticket price, in which case the ticket is purchased and the session
+// is reset.  The participants are priced out and the session is also reset
+// when the maximum number of participants is reached without covering the
+// ticket price.
+func (ss *splitSession) join(sh *stakeholder, contribution float64, ticketPrice int64, maxParticipants int) bool {
+	ss.participants = append(ss.participants, sh)
+	ss.total += contribution
+	if ss.total >= float64(ticketPrice) {
+		for _, participant := range ss.participants {
+			participant.splitTickets++
+		}
+		ss.reset()
+		return true
+	}
+	if len(ss.participants) >= maxParticipants {
+		ss.abandon()
+	}
+	return false
+}
+
+// abandon marks all of the participants of the split session as priced out
+// and resets it.
+func (ss *splitSession) abandon() {
+	for _, participant := range ss.participants {
+		participant.pricedOut++
+	}
+	ss.reset()
+}
+
+// reset removes all participants from the split session.
+func (ss *splitSession) reset() {
+	ss.participants = ss.participants[:0]
+	ss.total = 0
+}
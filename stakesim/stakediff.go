@@ -0,0 +1,223 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"github.com/davecgh/dcrstakesim/internal/tickettreap"
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrutil"
+)
+
+// BlockInfo houses the details about a block in the simulated chain that ticket
+// price algorithms may depend on.  It is a copy of the values in the chain, so
+// modifying it has no effect on the simulation.
+type BlockInfo struct {
+	Height          int32
+	TicketPrice     int64
+	PoolSize        uint32
+	NumImmature     uint32
+	NewTickets      uint8
+	NumVoters       uint16
+	TotalSupply     dcrutil.Amount
+	SpendableSupply dcrutil.Amount
+	StakedCoins     dcrutil.Amount
+}
+
+// blockInfo returns the details about the passed block that are provided to
+// ticket price algorithms or nil when the block is nil.
+func blockInfo(node *BlockNode) *BlockInfo {
+	if node == nil {
+		return nil
+	}
+	return &BlockInfo{
+		Height:          node.height,
+		TicketPrice:     node.ticketPrice,
+		PoolSize:        node.poolSize,
+		NumImmature:     node.numImmature,
+		NewTickets:      uint8(len(node.ticketsAdded)),
+		NumVoters:       node.numVoters,
+		TotalSupply:     node.totalSupply,
+		SpendableSupply: node.spendableSupply,
+		StakedCoins:     node.stakedCoins,
+	}
+}
+
+// ChainView provides read-only access to a chain for ticket price algorithms.
+// The simulator provides a view of the simulated chain, while other
+// implementations allow algorithms to be tested against synthetic chains.
+type ChainView interface {
+	// Params returns the network params of the chain.
+	Params() *chaincfg.Params
+
+	// Tip returns the details about the most recent block of the chain or
+	// nil when it does not have any blocks yet.
+	Tip() *BlockInfo
+
+	// Ancestor returns the details about the block of the chain at the
+	// provided height or nil when the height is negative or after the tip.
+	Ancestor(height int32) *BlockInfo
+
+	// NewTickets returns the total number of tickets purchased in the
+	// blocks from the provided start height through the end height, such
+	// as all of the blocks in a stake difficulty window.  Heights outside
+	// of the chain are ignored.
+	NewTickets(startHeight, endHeight int32) int64
+
+	// ImmatureTickets returns the number of purchased tickets that have not
+	// matured as of the tip.
+	ImmatureTickets() int
+
+	// PoolSize returns the number of tickets in the live ticket pool as of
+	// the tip.
+	PoolSize() int
+
+	// AvgTicketPrice returns the average purchase price of all immature and
+	// live tickets as of the tip or zero when there are none.
+	AvgTicketPrice() int64
+
+	// EstimatedSupply returns an estimate of the coin supply as of the
+	// provided height.
+	EstimatedSupply(height int32) dcrutil.Amount
+}
+
+// StakeDiffAlgorithm calculates the required stake difficulty (aka ticket
+// price) for the block after the tip of the passed chain view.  Algorithms only
+// have read-only access to the chain through the view, so they are decoupled
+// from the simulator.
+type StakeDiffAlgorithm interface {
+	NextStakeDiff(chain ChainView) int64
+}
+
+// StatefulStakeDiffAlgorithm is a StakeDiffAlgorithm which keeps state across
+// blocks, such as the integral term of a controller or the accumulator of a
+// moving average.  The simulator notifies it about every block connected to the
+// chain so the state can be updated as the chain grows, which also allows the
+// calculation of the next price to be free of side effects.  The state is
+// included in snapshots of the simulator.
+type StatefulStakeDiffAlgorithm interface {
+	StakeDiffAlgorithm
+
+	// BlockConnected is invoked after a block is connected to the chain,
+	// so the tip of the passed chain view is the new block.
+	BlockConnected(chain ChainView)
+
+	// Retarget is invoked after BlockConnected when the connected block is
+	// the first block of a new stake difficulty window, so the tip of the
+	// passed chain view has the price calculated for the window.
+	Retarget(chain ChainView)
+
+	// Snapshot returns a copy of the current state of the algorithm which
+	// must not be affected by any later changes to the state.
+	Snapshot() interface{}
+
+	// Restore replaces the current state of the algorithm with the passed
+	// state, which was previously returned by Snapshot.
+	Restore(state interface{})
+}
+
+// StakeDiffFunc is an adapter which allows an ordinary function to be used as a
+// StakeDiffAlgorithm.
+type StakeDiffFunc func(chain ChainView) int64
+
+// NextStakeDiff calls the function.  It is part of the StakeDiffAlgorithm
+// interface implementation.
+func (f StakeDiffFunc) NextStakeDiff(chain ChainView) int64 {
+	return f(chain)
+}
+
+// statelessAlgorithm returns a function which returns the passed function as a
+// StakeDiffAlgorithm for use with price functions whose algorithm does not
+// keep any state, so every simulator can share it.
+func statelessAlgorithm(f StakeDiffFunc) func() StakeDiffAlgorithm {
+	return func() StakeDiffAlgorithm { return f }
+}
+
+// chainView provides a read-only view of the chain of a simulator.  It
+// implements the ChainView interface.
+type chainView struct {
+	s *Simulator
+}
+
+// Ensure chainView implements the ChainView interface.
+var _ ChainView = chainView{}
+
+// Params returns the network params of the simulated chain.  It is part of the
+// ChainView interface implementation.
+func (v chainView) Params() *chaincfg.Params {
+	return v.s.params
+}
+
+// Tip returns the details about the most recent block of the simulated chain.
+// It is part of the ChainView interface implementation.
+func (v chainView) Tip() *BlockInfo {
+	return blockInfo(v.s.tip)
+}
+
+// Ancestor returns the details about the block of the simulated chain at the
+// provided height.  It is part of the ChainView interface implementation.
+func (v chainView) Ancestor(height int32) *BlockInfo {
+	if height < 0 {
+		return nil
+	}
+	return blockInfo(v.s.ancestorNode(v.s.tip, height, nil))
+}
+
+// NewTickets returns the total number of tickets purchased in the blocks of the
+// simulated chain from the provided start height through the end height.  It is
+// part of the ChainView interface implementation.
+func (v chainView) NewTickets(startHeight, endHeight int32) int64 {
+	if startHeight < 0 {
+		startHeight = 0
+	}
+	if startHeight > endHeight {
+		return 0
+	}
+	var newTickets int64
+	node := v.s.ancestorNode(v.s.tip, endHeight, nil)
+	for node != nil && node.height >= startHeight {
+		newTickets += int64(len(node.ticketsAdded))
+		node = node.parent
+	}
+	return newTickets
+}
+
+// ImmatureTickets returns the number of purchased tickets that have not matured
+// as of the tip of the simulated chain.  It is part of the ChainView interface
+// implementation.
+func (v chainView) ImmatureTickets() int {
+	return len(v.s.immatureTickets)
+}
+
+// PoolSize returns the number of tickets in the live ticket pool as of the tip
+// of the simulated chain.  It is part of the ChainView interface
+// implementation.
+func (v chainView) PoolSize() int {
+	return v.s.liveTickets.Len()
+}
+
+// AvgTicketPrice returns the average purchase price of all immature and live
+// tickets as of the tip of the simulated chain.  It is part of the ChainView
+// interface implementation.
+func (v chainView) AvgTicketPrice() int64 {
+	totalTickets := int64(len(v.s.immatureTickets) + v.s.liveTickets.Len())
+	if totalTickets == 0 {
+		return 0
+	}
+	var totalSpent int64
+	for _, ticket := range v.s.immatureTickets {
+		totalSpent += int64(ticket.price)
+	}
+	v.s.liveTickets.ForEach(func(k tickettreap.Key, val *tickettreap.Value) bool {
+		totalSpent += val.PurchasePrice
+		return true
+	})
+	return totalSpent / totalTickets
+}
+
+// EstimatedSupply returns an estimate of the coin supply as of the provided
+// height.  It is part of the ChainView interface implementation.
+func (v chainView) EstimatedSupply(height int32) dcrutil.Amount {
+	return estimateSupply(v.s.params, height)
+}
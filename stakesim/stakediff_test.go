@@ -0,0 +1,228 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"testing"
+
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrutil"
+)
+
+// fakeChain is a synthetic chain which implements the ChainView interface so
+// ticket price algorithms can be tested without running a simulation.
+type fakeChain struct {
+	params          *chaincfg.Params
+	blocks          []BlockInfo
+	immatureTickets int
+	avgTicketPrice  int64
+}
+
+// Ensure fakeChain implements the ChainView interface.
+var _ ChainView = (*fakeChain)(nil)
+
+// newFakeChain returns a synthetic chain on the network defined by the passed
+// params with the provided number of blocks which all have the same ticket
+// price, pool size, and number of new tickets.
+func newFakeChain(params *chaincfg.Params, numBlocks int32, ticketPrice int64, poolSize uint32, newTickets uint8) *fakeChain {
+	chain := &fakeChain{
+		params:         params,
+		avgTicketPrice: ticketPrice,
+	}
+	for height := int32(0); height < numBlocks; height++ {
+		chain.blocks = append(chain.blocks, BlockInfo{
+			Height:      height,
+			TicketPrice: ticketPrice,
+			PoolSize:    poolSize,
+			NewTickets:  newTickets,
+			TotalSupply: estimateSupply(params, height),
+			StakedCoins: dcrutil.Amount(ticketPrice) *
+				dcrutil.Amount(poolSize),
+		})
+	}
+	return chain
+}
+
+// Params returns the network params of the fake chain.  It is part of the
+// ChainView interface implementation.
+func (c *fakeChain) Params() *chaincfg.Params {
+	return c.params
+}
+
+// Tip returns the most recent block of the fake chain.  It is part of the
+// ChainView interface implementation.
+func (c *fakeChain) Tip() *BlockInfo {
+	if len(c.blocks) == 0 {
+		return nil
+	}
+	return c.Ancestor(int32(len(c.blocks) - 1))
+}
+
+// Ancestor returns the block of the fake chain at the provided height.  It is
+// part of the ChainView interface implementation.
+func (c *fakeChain) Ancestor(height int32) *BlockInfo {
+	if height < 0 || int(height) >= len(c.blocks) {
+		return nil
+	}
+	block := c.blocks[height]
+	return &block
+}
+
+// NewTickets returns the total number of tickets purchased in the blocks of the
+// fake chain in the provided range.  It is part of the ChainView interface
+// implementation.
+func (c *fakeChain) NewTickets(startHeight, endHeight int32) int64 {
+	var newTickets int64
+	for height := startHeight; height <= endHeight; height++ {
+		if block := c.Ancestor(height); block != nil {
+			newTickets += int64(block.NewTickets)
+		}
+	}
+	return newTickets
+}
+
+// ImmatureTickets returns the configured number of immature tickets.  It is
+// part of the ChainView interface implementation.
+func (c *fakeChain) ImmatureTickets() int {
+	return c.immatureTickets
+}
+
+// PoolSize returns the pool size as of the tip of the fake chain.  It is part
+// of the ChainView interface implementation.
+func (c *fakeChain) PoolSize() int {
+	if tip := c.Tip(); tip != nil {
+		return int(tip.PoolSize)
+	}
+	return 0
+}
+
+// AvgTicketPrice returns the configured average ticket price.  It is part of
+// the ChainView interface implementation.
+func (c *fakeChain) AvgTicketPrice() int64 {
+	return c.avgTicketPrice
+}
+
+// EstimatedSupply returns an estimate of the coin supply as of the provided
+// height.  It is part of the ChainView interface implementation.
+func (c *fakeChain) EstimatedSupply(height int32) dcrutil.Amount {
+	return estimateSupply(c.params, height)
+}
+
+// TestStakeDiffBeforeStart ensures every registered ticket price algorithm
+// returns the minimum stake difficulty until tickets can possibly be purchased
+// due to coinbase maturity.
+func TestStakeDiffBeforeStart(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	startHeight := int32(params.CoinbaseMaturity) + 1
+
+	tests := []struct {
+		name      string
+		numBlocks int32
+	}{
+		{name: "no blocks", numBlocks: 0},
+		{name: "genesis only", numBlocks: 1},
+		{name: "last block before start", numBlocks: startHeight - 1},
+	}
+
+	for _, test := range tests {
+		for _, key := range PriceFuncKeys() {
+			chain := newFakeChain(params, test.numBlocks,
+				3*params.MinimumStakeDiff, 0, 0)
+			alg := LookupPriceFunc(key).NewAlgorithm()
+			got := alg.NextStakeDiff(chain)
+			if got != params.MinimumStakeDiff {
+				t.Errorf("%s: price func %s: got %d, want %d",
+					test.name, key, got,
+					params.MinimumStakeDiff)
+			}
+		}
+	}
+}
+
+// TestStakeDiffWindowBoundary ensures every registered ticket price algorithm
+// only changes the price at the start of a stake difficulty window and keeps
+// the price of the tip for all other blocks.
+func TestStakeDiffWindowBoundary(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	windowSize := int32(params.StakeDiffWindowSize)
+	targetPoolSize := uint32(params.TicketsPerBlock) *
+		uint32(params.TicketPoolSize)
+	const curDiff = 5000000000
+
+	tests := []struct {
+		name      string
+		numBlocks int32
+	}{
+		{name: "first block of window", numBlocks: 30*windowSize + 1},
+		{name: "middle of window", numBlocks: 30*windowSize + 72},
+		{name: "last block of window", numBlocks: 31*windowSize - 1},
+	}
+
+	for _, test := range tests {
+		for _, key := range PriceFuncKeys() {
+			chain := newFakeChain(params, test.numBlocks, curDiff,
+				targetPoolSize/2, uint8(params.TicketsPerBlock))
+			alg := LookupPriceFunc(key).NewAlgorithm()
+			got := alg.NextStakeDiff(chain)
+			if got != curDiff {
+				t.Errorf("%s: price func %s: got %d, want %d",
+					test.name, key, got, curDiff)
+			}
+		}
+	}
+}
+
+// TestStakeDiffEmptyPool ensures the ticket price algorithms produce the
+// expected price at a retarget when no tickets have ever been purchased, so
+// the ticket pool is empty.
+func TestStakeDiffEmptyPool(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	windowSize := int32(params.StakeDiffWindowSize)
+	numBlocks := 30 * windowSize
+	curDiff := 3 * params.MinimumStakeDiff
+
+	tests := []struct {
+		key  string
+		want int64
+	}{
+		// The proposals that compare the pool size against that of
+		// the previous window keep the existing price when there was
+		// no pool to compare against.
+		{key: "1", want: curDiff},
+		{key: "1E", want: curDiff},
+		{key: "1F", want: curDiff},
+		{key: "1G", want: curDiff},
+		{key: "1H", want: curDiff},
+		{key: "1R", want: curDiff},
+		{key: "6", want: curDiff},
+		{key: "7", want: curDiff},
+
+		// The proposals that are driven by the shortfall from the
+		// target pool size drop to the minimum price.
+		{key: "current", want: params.MinimumStakeDiff},
+		{key: "3", want: params.MinimumStakeDiff},
+		{key: "5", want: params.MinimumStakeDiff},
+
+		// Proposal 2 prices relative to the average ticket price, which
+		// is the existing price, and raises it along its curve for a
+		// pool that is the full target size under its target.
+		{key: "2", want: 845353615},
+
+		// Proposal 4 lowers the price by a third of its bounds, which
+		// are a quarter of the existing price on mainnet, since no
+		// tickets were purchased.
+		{key: "4", want: curDiff - curDiff/12},
+	}
+
+	for _, test := range tests {
+		chain := newFakeChain(params, numBlocks, curDiff, 0, 0)
+		alg := LookupPriceFunc(test.key).NewAlgorithm()
+		got := alg.NextStakeDiff(chain)
+		if got != test.want {
+			t.Errorf("price func %s: got %d, want %d", test.key,
+				got, test.want)
+		}
+	}
+}
@@ -0,0 +1,454 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/decred/dcrutil"
+)
+
+// DistStats houses statistics about the distribution of a series of values.
+type DistStats struct {
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	StdDev float64 `json:"stdDev"`
+}
+
+// calcDistStats returns the mean, median, and population standard deviation of
+// the passed values.  The passed slice will be sorted in place.  Zero values
+// are returned when there are no values.
+func calcDistStats(values []float64) DistStats {
+	if len(values) == 0 {
+		return DistStats{}
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	var sumSquares float64
+	for _, v := range values {
+		sumSquares += (v - mean) * (v - mean)
+	}
+
+	sort.Float64s(values)
+	median := values[len(values)/2]
+	if len(values)%2 == 0 {
+		median = (values[len(values)/2-1] + median) / 2
+	}
+
+	return DistStats{
+		Mean:   mean,
+		Median: median,
+		StdDev: math.Sqrt(sumSquares / float64(len(values))),
+	}
+}
+
+// Summary houses summary details about a completed simulation.  It is
+// intended to be serialized to JSON so the results of a simulation can be
+// consumed by other tools and different price functions can be compared
+// numerically.
+//
+// All amounts are in atoms.  The ticket price, pool size, and staked fraction
+// statistics only consider blocks after stake validation height unless the
+// entire simulation is before that point.
+//
+// The summary of a simulation that failed only identifies the run along with
+// the error that stopped it, its code when it is a simulation error, and the
+// height of the last block that was simulated.
+type Summary struct {
+	ResultsPath     string  `json:"resultsPath,omitempty"`
+	PriceFunc       string  `json:"priceFunc,omitempty"`
+	DemandFunc      string  `json:"demandFunc,omitempty"`
+	Seed            int64   `json:"seed"`
+	Error           string  `json:"error,omitempty"`
+	ErrorCode       string  `json:"errorCode,omitempty"`
+	Duration        float64 `json:"durationSecs,omitempty"`
+	Height          int32   `json:"height"`
+	MinTicketPrice  int64   `json:"minTicketPrice"`
+	MaxTicketPrice  int64   `json:"maxTicketPrice"`
+	MinPoolSize     uint32  `json:"minPoolSize"`
+	MaxPoolSize     uint32  `json:"maxPoolSize"`
+	NumTickets      int     `json:"numTickets"`
+	NumWinners      int     `json:"numWinners"`
+	NumExpired      int     `json:"numExpired"`
+	ExpiredPercent  float64 `json:"expiredPercent"`
+	TotalSupply     int64   `json:"totalSupply"`
+	SpendableSupply int64   `json:"spendableSupply"`
+
+	TicketPrice DistStats `json:"ticketPrice"`
+	PoolSize    DistStats `json:"poolSize"`
+
+	// TargetPoolSize is the number of tickets the ticket price algorithm
+	// should ideally maintain in the live ticket pool.  PoolSizeBand is the
+	// percentage above and below the target pool size that is considered
+	// acceptable and the remaining fields report how many blocks, and the
+	// percentage of them, where the pool size was outside of that band.
+	TargetPoolSize     int64   `json:"targetPoolSize"`
+	PoolSizeBand       float64 `json:"poolSizeBandPercent"`
+	BlocksOutsideBand  int     `json:"blocksOutsideBand"`
+	PercentOutsideBand float64 `json:"percentOutsideBand"`
+
+	// AvgVoteWaitBlocks and AvgVoteWaitDays are the average amount of time
+	// between purchasing a ticket and it voting.
+	AvgVoteWaitBlocks float64 `json:"avgVoteWaitBlocks"`
+	AvgVoteWaitDays   float64 `json:"avgVoteWaitDays"`
+
+	// MissRate is the fraction of winning tickets which failed to vote and
+	// ExpiryRate is the fraction of all tickets which expired.
+	NumMissed  int     `json:"numMissed"`
+	MissRate   float64 `json:"missRate"`
+	ExpiryRate float64 `json:"expiryRate"`
+
+	// NumRevoked is the number of missed and expired tickets which were
+	// revoked and AvgRevokeDelayBlocks is the average number of blocks
+	// between them becoming eligible to be revoked and being revoked.
+	// NumUnrevoked and UnrevokedCoins are the number of tickets and the
+	// amount of coins still locked in missed and expired tickets that have
+	// not been revoked as of the final block.
+	NumRevoked           int     `json:"numRevoked"`
+	AvgRevokeDelayBlocks float64 `json:"avgRevokeDelayBlocks"`
+	NumUnrevoked         int     `json:"numUnrevoked"`
+	UnrevokedCoins       int64   `json:"unrevokedCoins"`
+
+	// AvgStakedFraction is the average fraction of the total supply that
+	// was staked.
+	AvgStakedFraction float64 `json:"avgStakedFraction"`
+
+	// WhaleTickets and SmallHolderTickets are the number of tickets
+	// purchased by whales and the remaining stakeholders, respectively,
+	// and StrategyTickets is the number purchased by the stakeholders using
+	// each strategy when purchases are decided by stakeholder agents.
+	WhaleTickets       int            `json:"whaleTickets,omitempty"`
+	SmallHolderTickets int            `json:"smallHolderTickets,omitempty"`
+	StrategyTickets    map[string]int `json:"strategyTickets,omitempty"`
+
+	// SplitTickets is the number of tickets purchased jointly by
+	// stakeholders that could not afford a full ticket.
+	// PricedOutStakeholders is the number of stakeholders that decided to
+	// purchase at least once but never afforded a full ticket nor shared a
+	// split ticket, and PricedOutAttempts is the number of times any
+	// stakeholder decided to purchase but was priced out.
+	SplitTickets          int `json:"splitTickets,omitempty"`
+	PricedOutStakeholders int `json:"pricedOutStakeholders,omitempty"`
+	PricedOutAttempts     int `json:"pricedOutAttempts,omitempty"`
+
+	// Mempool summarizes the simulated ticket mempool when it is enabled.
+	Mempool *MempoolSummary `json:"mempool,omitempty"`
+
+	// Solo and Pooled summarize the returns of the tickets held by solo
+	// stakers and those delegated to VSPs, respectively, when VSPs are
+	// simulated.
+	Solo   *StakerSummary `json:"solo,omitempty"`
+	Pooled *StakerSummary `json:"pooled,omitempty"`
+
+	// Verify houses the results of verifying the simulated blocks against
+	// the block headers of the CSV input data when it is enabled.
+	Verify *VerifySummary `json:"verify,omitempty"`
+}
+
+// StakerSummary houses summary details about the returns of a group of tickets.
+// Only tickets which voted, missed, or expired are considered since the
+// returns of the others are not yet known.
+type StakerSummary struct {
+	NumTickets int     `json:"numTickets"`
+	NumVoted   int     `json:"numVoted"`
+	NumMissed  int     `json:"numMissed"`
+	NumExpired int     `json:"numExpired"`
+	MissRate   float64 `json:"missRate"`
+
+	// VoteRewards is the total vote reward of the tickets which voted and
+	// Fees is the portion of it paid to VSPs.
+	VoteRewards int64 `json:"voteRewards"`
+	Fees        int64 `json:"fees"`
+
+	// NetReturn is the vote rewards less fees as a fraction of the amount
+	// spent on the tickets, and NetYield is the same return annualized by
+	// the amount of time the coins were locked in the tickets.
+	NetReturn float64 `json:"netReturn"`
+	NetYield  float64 `json:"netYield"`
+}
+
+// summarizeStakers returns summaries of the returns of the tickets held by solo
+// stakers and those delegated to VSPs in the chain of the passed simulator,
+// respectively.  Missed tickets are considered locked until they missed their
+// vote and expired tickets until they expired.
+func summarizeStakers(s *Simulator) (*StakerSummary, *StakerSummary) {
+	// Shorter version of some params for convenience.
+	ticketsPerBlock := dcrutil.Amount(s.params.TicketsPerBlock)
+	expiryBlocks := int32(s.params.TicketMaturity) +
+		int32(s.params.TicketExpiry)
+	blocksPerYear := float64(time.Hour*24*365) /
+		float64(s.params.TargetTimePerBlock)
+
+	var solo, pooled StakerSummary
+	var soloSpent, pooledSpent, soloLocked, pooledLocked float64
+	tally := func(ticket *StakeTicket, lockedBlocks int32) *StakerSummary {
+		spent := float64(ticket.price)
+		locked := spent * float64(lockedBlocks)
+		if ticket.vsp == nil {
+			soloSpent += spent
+			soloLocked += locked
+			solo.NumTickets++
+			return &solo
+		}
+		pooledSpent += spent
+		pooledLocked += locked
+		pooled.NumTickets++
+		return &pooled
+	}
+	for node := s.root; node != nil; node = node.next {
+		if len(node.ticketsVoted) == 0 {
+			continue
+		}
+		posSubsidy := s.CalcPoSSubsidy(node.height - 1)
+		perVoteSubsidy := posSubsidy / ticketsPerBlock
+		for _, ticket := range node.ticketsVoted {
+			ss := tally(ticket, ticket.winHeight-ticket.blockHeight)
+			ss.NumVoted++
+			ss.VoteRewards += int64(perVoteSubsidy)
+			if ticket.vsp != nil {
+				ss.Fees += int64(float64(perVoteSubsidy) *
+					ticket.vsp.fee)
+			}
+		}
+	}
+	for _, ticket := range s.missedTickets {
+		ss := tally(ticket, ticket.winHeight-ticket.blockHeight)
+		ss.NumMissed++
+	}
+	for _, ticket := range s.expiredTickets {
+		ss := tally(ticket, expiryBlocks)
+		ss.NumExpired++
+	}
+
+	finish := func(ss *StakerSummary, spent, locked float64) {
+		if numWinners := ss.NumVoted + ss.NumMissed; numWinners > 0 {
+			ss.MissRate = float64(ss.NumMissed) / float64(numWinners)
+		}
+		netRewards := float64(ss.VoteRewards - ss.Fees)
+		if spent > 0 {
+			ss.NetReturn = netRewards / spent
+		}
+		if locked > 0 {
+			ss.NetYield = netRewards / locked * blocksPerYear
+		}
+	}
+	finish(&solo, soloSpent, soloLocked)
+	finish(&pooled, pooledSpent, pooledLocked)
+	return &solo, &pooled
+}
+
+// MempoolSummary houses summary details about the simulated ticket mempool.
+// Fee rates are in DCR/kB.
+type MempoolSummary struct {
+	// NumMined and NumExpired are the number of ticket purchases that were
+	// included in a block and that expired due to a ticket price change
+	// while waiting, respectively.
+	NumMined   int `json:"numMined"`
+	NumExpired int `json:"numExpired"`
+
+	// AvgDepth and MaxDepth are the average and maximum number of ticket
+	// purchases waiting after each block.
+	AvgDepth float64 `json:"avgDepth"`
+	MaxDepth uint32  `json:"maxDepth"`
+
+	// AvgWaitBlocks is the average number of blocks the purchases that
+	// were included in a block waited.
+	AvgWaitBlocks float64 `json:"avgWaitBlocks"`
+
+	// AvgMinedFeeRate and AvgExpiredFeeRate are the average fee rates of
+	// the purchases that were included in a block and that expired.
+	AvgMinedFeeRate   float64 `json:"avgMinedFeeRate"`
+	AvgExpiredFeeRate float64 `json:"avgExpiredFeeRate"`
+}
+
+// summarizeMempool returns a summary of the simulated ticket mempool of the
+// passed simulator, which must have it enabled.
+func summarizeMempool(s *Simulator) *MempoolSummary {
+	mp := s.mempool
+	summary := &MempoolSummary{
+		NumMined:   mp.numMined,
+		NumExpired: mp.numExpired,
+	}
+	var depthSum float64
+	var numBlocks int
+	for node := s.root; node != nil; node = node.next {
+		depthSum += float64(node.mempoolDepth)
+		if node.mempoolDepth > summary.MaxDepth {
+			summary.MaxDepth = node.mempoolDepth
+		}
+		numBlocks++
+	}
+	if numBlocks > 0 {
+		summary.AvgDepth = depthSum / float64(numBlocks)
+	}
+	if mp.numMined > 0 {
+		summary.AvgWaitBlocks = mp.waitSum / float64(mp.numMined)
+		summary.AvgMinedFeeRate = mp.minedFeeSum / float64(mp.numMined)
+	}
+	if mp.numExpired > 0 {
+		summary.AvgExpiredFeeRate = mp.expiredFeeSum /
+			float64(mp.numExpired)
+	}
+	return summary
+}
+
+// Summarize returns a summary of the chain and ticket pools of the
+// passed simulator.  The pool size band is the percentage above and below the
+// target pool size the pool size is considered acceptable for the purposes of
+// reporting how long it spent outside of the band.
+//
+// An empty summary with a height of -1 is returned when the simulator has not
+// connected any blocks.
+func Summarize(s *Simulator, poolSizeBand float64) *Summary {
+	// Shorter version of some params for convenience.
+	stakeValidationHeight := int32(s.params.StakeValidationHeight)
+	ticketsPerBlock := int64(s.params.TicketsPerBlock)
+	targetPoolSize := ticketsPerBlock * int64(s.params.TicketPoolSize)
+	bandDelta := float64(targetPoolSize) * poolSizeBand / 100
+
+	if s.tip == nil {
+		return &Summary{
+			Height:         -1,
+			TargetPoolSize: targetPoolSize,
+			PoolSizeBand:   poolSizeBand,
+		}
+	}
+
+	var ticketPrices, poolSizes []float64
+	var stakedFractionSum, voteWaitSum, revokeDelaySum float64
+	var blocksOutsideBand, numVoted, numRevoked int
+	minTicketPrice, maxTicketPrice := int64(math.MaxInt64), int64(0)
+	minPoolSize, maxPoolSize := uint32(math.MaxUint32), uint32(0)
+	for node := s.root; node != nil; node = node.next {
+		if node.ticketPrice < minTicketPrice {
+			minTicketPrice = node.ticketPrice
+		}
+		if node.ticketPrice > maxTicketPrice {
+			maxTicketPrice = node.ticketPrice
+		}
+
+		// Tally the amount of time between purchase and voting for all
+		// tickets that voted.
+		for _, ticket := range node.ticketsVoted {
+			voteWaitSum += float64(ticket.winHeight - ticket.blockHeight)
+			numVoted++
+		}
+
+		// Tally the amount of time between becoming eligible to be
+		// revoked and being revoked for all tickets that were revoked.
+		for _, ticket := range node.ticketsRevoked {
+			revokeDelaySum += float64(node.height -
+				ticket.revocableHeight)
+			numRevoked++
+		}
+
+		// Only consider pool size after stake validation height unless
+		// the entire simulation is before that point.
+		if node.height < stakeValidationHeight && s.tip.height >= stakeValidationHeight {
+			continue
+		}
+		if node.poolSize < minPoolSize {
+			minPoolSize = node.poolSize
+		}
+		if node.poolSize > maxPoolSize {
+			maxPoolSize = node.poolSize
+		}
+		ticketPrices = append(ticketPrices, float64(node.ticketPrice))
+		poolSizes = append(poolSizes, float64(node.poolSize))
+		if math.Abs(float64(node.poolSize)-float64(targetPoolSize)) > bandDelta {
+			blocksOutsideBand++
+		}
+		if node.totalSupply > 0 {
+			stakedFractionSum += float64(node.stakedCoins) /
+				float64(node.totalSupply)
+		}
+	}
+	totalTickets := s.liveTickets.Len() + len(s.immatureTickets) +
+		len(s.wonTickets) + len(s.expiredTickets)
+	var expiredPercent float64
+	if totalTickets > 0 {
+		expiredPercent = float64(len(s.expiredTickets)) * 100 /
+			float64(totalTickets)
+	}
+
+	summary := &Summary{
+		Height:          s.tip.height,
+		MinTicketPrice:  minTicketPrice,
+		MaxTicketPrice:  maxTicketPrice,
+		MinPoolSize:     minPoolSize,
+		MaxPoolSize:     maxPoolSize,
+		NumTickets:      totalTickets,
+		NumWinners:      len(s.wonTickets),
+		NumExpired:      len(s.expiredTickets),
+		ExpiredPercent:  expiredPercent,
+		TotalSupply:     int64(s.tip.totalSupply),
+		SpendableSupply: int64(s.tip.spendableSupply),
+		TicketPrice:     calcDistStats(ticketPrices),
+		PoolSize:        calcDistStats(poolSizes),
+		TargetPoolSize:  targetPoolSize,
+		PoolSizeBand:    poolSizeBand,
+		NumMissed:       len(s.missedTickets),
+		ExpiryRate:      expiredPercent / 100,
+	}
+	if numBlocks := len(poolSizes); numBlocks > 0 {
+		summary.BlocksOutsideBand = blocksOutsideBand
+		summary.PercentOutsideBand = float64(blocksOutsideBand) * 100 /
+			float64(numBlocks)
+		summary.AvgStakedFraction = stakedFractionSum / float64(numBlocks)
+	}
+	if numVoted > 0 {
+		avgWait := voteWaitSum / float64(numVoted)
+		blocksPerDay := float64(time.Hour*24) /
+			float64(s.params.TargetTimePerBlock)
+		summary.AvgVoteWaitBlocks = avgWait
+		summary.AvgVoteWaitDays = avgWait / blocksPerDay
+	}
+	summary.NumRevoked = numRevoked
+	if numRevoked > 0 {
+		summary.AvgRevokeDelayBlocks = revokeDelaySum /
+			float64(numRevoked)
+	}
+	summary.NumUnrevoked = len(s.unrevokedTickets)
+	for _, ticket := range s.unrevokedTickets {
+		summary.UnrevokedCoins += int64(ticket.price)
+	}
+	if numWinners := len(s.wonTickets); numWinners > 0 {
+		summary.MissRate = float64(len(s.missedTickets)) /
+			float64(numWinners)
+	}
+	if s.mempool != nil {
+		summary.Mempool = summarizeMempool(s)
+	}
+	if len(s.vsps) > 0 {
+		summary.Solo, summary.Pooled = summarizeStakers(s)
+	}
+	summary.Verify = s.verify
+	if s.agents != nil {
+		summary.StrategyTickets = make(map[string]int)
+		for _, sh := range s.agents.stakeholders {
+			if sh.whale {
+				summary.WhaleTickets += sh.ticketsBought
+			} else {
+				summary.SmallHolderTickets += sh.ticketsBought
+			}
+			summary.StrategyTickets[sh.strategy.String()] +=
+				sh.ticketsBought
+			if sh.isPricedOut() {
+				summary.PricedOutStakeholders++
+			}
+			summary.PricedOutAttempts += sh.pricedOut
+		}
+		summary.SplitTickets = s.agents.numSplitTickets
+	}
+
+	return summary
+}
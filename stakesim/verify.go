@@ -0,0 +1,99 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"fmt"
+
+	"github.com/decred/dcrd/wire"
+)
+
+// Divergence houses the values calculated by the simulator for a block along
+// with the values committed to by its real header when they differ.
+type Divergence struct {
+	Height            int32  `json:"height"`
+	TicketPrice       int64  `json:"ticketPrice"`
+	HeaderTicketPrice int64  `json:"headerTicketPrice"`
+	PoolSize          uint32 `json:"poolSize"`
+	HeaderPoolSize    uint32 `json:"headerPoolSize"`
+}
+
+// VerifySummary houses the results of comparing the ticket price and pool size
+// the simulator calculated for every block against the stake difficulty and
+// pool size committed to by the real block headers in the CSV input data.
+//
+// Only the ticket price function used by the network the data was extracted
+// from is expected to match.
+type VerifySummary struct {
+	NumBlocks          int `json:"numBlocks"`
+	PriceMismatches    int `json:"priceMismatches"`
+	PoolSizeMismatches int `json:"poolSizeMismatches"`
+
+	// MaxPriceDiff and MaxPoolSizeDiff are the largest absolute differences
+	// between the calculated and committed values of any block.
+	MaxPriceDiff    int64 `json:"maxPriceDiff"`
+	MaxPoolSizeDiff int64 `json:"maxPoolSizeDiff"`
+
+	// FirstDivergence is the first block whose calculated values differ
+	// from those committed to by its header.  It is nil when they all match.
+	FirstDivergence *Divergence `json:"firstDivergence,omitempty"`
+}
+
+// String returns a human-readable description of the verification results.
+func (v *VerifySummary) String() string {
+	if v.FirstDivergence == nil {
+		return fmt.Sprintf("all %d blocks match the ticket price and "+
+			"pool size of their headers", v.NumBlocks)
+	}
+	d := v.FirstDivergence
+	return fmt.Sprintf("%d of %d blocks have a different ticket price "+
+		"and %d a different pool size than their headers (max "+
+		"differences %d atoms and %d tickets), first diverging at "+
+		"height %d with ticket price %d (header %d) and pool size %d "+
+		"(header %d)", v.PriceMismatches, v.NumBlocks,
+		v.PoolSizeMismatches, v.MaxPriceDiff, v.MaxPoolSizeDiff,
+		d.Height, d.TicketPrice, d.HeaderTicketPrice, d.PoolSize,
+		d.HeaderPoolSize)
+}
+
+// absDiff returns the absolute difference between the passed values.
+func absDiff(a, b int64) int64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// verifyNode compares the ticket price and pool size the simulator calculated
+// for the passed block against the stake difficulty and pool size committed to
+// by its real header and records any differences.  The pool size of a block is
+// the size of the live ticket pool before the block is connected, which is
+// the same value its header commits to.
+func (v *VerifySummary) verifyNode(node *BlockNode, header *wire.BlockHeader) {
+	v.NumBlocks++
+	priceDiff := absDiff(node.ticketPrice, header.SBits)
+	poolSizeDiff := absDiff(int64(node.poolSize), int64(header.PoolSize))
+	if priceDiff != 0 {
+		v.PriceMismatches++
+	}
+	if poolSizeDiff != 0 {
+		v.PoolSizeMismatches++
+	}
+	if priceDiff > v.MaxPriceDiff {
+		v.MaxPriceDiff = priceDiff
+	}
+	if poolSizeDiff > v.MaxPoolSizeDiff {
+		v.MaxPoolSizeDiff = poolSizeDiff
+	}
+	if v.FirstDivergence == nil && (priceDiff != 0 || poolSizeDiff != 0) {
+		v.FirstDivergence = &Divergence{
+			Height:            node.height,
+			TicketPrice:       node.ticketPrice,
+			HeaderTicketPrice: header.SBits,
+			PoolSize:          node.poolSize,
+			HeaderPoolSize:    header.PoolSize,
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright (c) 2017 Dave Collins
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stakesim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+)
+
+// VotingService describes a simulated voting service provider (VSP), also
+// known as a stakepool, which votes the tickets delegated to it on behalf of
+// their owners in exchange for a fee.
+type VotingService struct {
+	// share is the fraction of newly purchased tickets that are delegated
+	// to the VSP.
+	share float64
+
+	// fee is the fraction of the vote reward of every ticket the VSP votes
+	// that it keeps.
+	fee float64
+
+	// uptime is the probability the VSP is online to vote a ticket that
+	// was delegated to it when the ticket wins.
+	uptime float64
+}
+
+// String returns the VSP in the same form accepted by ParseVotingServices.
+func (vsp *VotingService) String() string {
+	return fmt.Sprintf("%s:%s:%s",
+		strconv.FormatFloat(vsp.share, 'f', -1, 64),
+		strconv.FormatFloat(vsp.fee, 'f', -1, 64),
+		strconv.FormatFloat(vsp.uptime, 'f', -1, 64))
+}
+
+// Description returns a human-readable description of the VSP.
+func (vsp *VotingService) Description() string {
+	percent := func(v float64) string {
+		return strconv.FormatFloat(v*100, 'f', -1, 64) + "%"
+	}
+	return fmt.Sprintf("%s of tickets, %s fee, %s uptime",
+		percent(vsp.share), percent(vsp.fee), percent(vsp.uptime))
+}
+
+// votingServiceJSON is the JSON representation of a VotingService used in
+// scenarios.
+type votingServiceJSON struct {
+	Share  float64 `json:"share"`
+	Fee    float64 `json:"fee"`
+	Uptime float64 `json:"uptime"`
+}
+
+// MarshalJSON encodes the VSP as a JSON object.
+func (vsp *VotingService) MarshalJSON() ([]byte, error) {
+	return json.Marshal(votingServiceJSON{vsp.share, vsp.fee, vsp.uptime})
+}
+
+// UnmarshalJSON decodes and validates a VSP from a JSON object.
+func (vsp *VotingService) UnmarshalJSON(data []byte) error {
+	var vj votingServiceJSON
+	if err := json.Unmarshal(data, &vj); err != nil {
+		return err
+	}
+	service := VotingService{share: vj.Share, fee: vj.Fee,
+		uptime: vj.Uptime}
+	if err := service.validate(); err != nil {
+		return err
+	}
+	*vsp = service
+	return nil
+}
+
+// validate returns an error when any of the attributes of the VSP are outside
+// of their valid range.
+func (vsp *VotingService) validate() error {
+	switch {
+	case vsp.share <= 0 || vsp.share > 1:
+		return fmt.Errorf("VSP %q must have a share in the range "+
+			"(0, 1]", vsp)
+	case vsp.fee < 0 || vsp.fee > 1:
+		return fmt.Errorf("VSP %q must have a fee in the range [0, 1]",
+			vsp)
+	case vsp.uptime < 0 || vsp.uptime > 1:
+		return fmt.Errorf("VSP %q must have an uptime in the range "+
+			"[0, 1]", vsp)
+	}
+	return nil
+}
+
+// parseFraction parses a fraction such as "0.02" or a percentage such as "2%".
+func parseFraction(str string) (float64, error) {
+	var scale float64 = 1
+	if strings.HasSuffix(str, "%") {
+		scale = 100
+		str = strings.TrimSuffix(str, "%")
+	}
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, err
+	}
+	return value / scale, nil
+}
+
+// ParseVotingServices parses a comma-separated list of VSPs of the form
+// share:fee:uptime, such as "0.3:0.02:0.999,20%:5%:99.5%".  Every value is
+// either a fraction or a percentage and the shares must not sum to more than
+// one since the remaining tickets are held by solo stakers.
+func ParseVotingServices(str string) ([]*VotingService, error) {
+	if strings.TrimSpace(str) == "" {
+		return nil, nil
+	}
+
+	var vsps []*VotingService
+	var totalShare float64
+	for _, vspStr := range SplitList(str) {
+		fields := strings.Split(vspStr, ":")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("VSP %q is not of the form "+
+				"share:fee:uptime", vspStr)
+		}
+		var values [3]float64
+		for i, field := range fields {
+			value, err := parseFraction(field)
+			if err != nil {
+				return nil, fmt.Errorf("VSP %q has an invalid "+
+					"value %q", vspStr, field)
+			}
+			values[i] = value
+		}
+		vsp := &VotingService{
+			share:  values[0],
+			fee:    values[1],
+			uptime: values[2],
+		}
+		if err := vsp.validate(); err != nil {
+			return nil, err
+		}
+		totalShare += vsp.share
+		vsps = append(vsps, vsp)
+	}
+	if totalShare > 1 {
+		return nil, fmt.Errorf("VSP shares sum to %v which is more "+
+			"than 1", totalShare)
+	}
+	return vsps, nil
+}
+
+// chooseVotingService returns the VSP a newly purchased ticket is delegated to
+// according to the shares of the simulated VSPs or nil when the ticket is held
+// by a solo staker.
+func (s *Simulator) chooseVotingService() *VotingService {
+	r := s.rng.Float64()
+	for _, vsp := range s.vsps {
+		if r < vsp.share {
+			return vsp
+		}
+		r -= vsp.share
+	}
+	return nil
+}
+
+// lookupVotingServices sets the VSP of each of the passed tickets, which must
+// be live, to the one the ticket was delegated to when it was purchased.  This
+// is necessary for tickets that were reconstructed from the live ticket pool,
+// such as the winning tickets, since it only tracks their purchase details.
+func (s *Simulator) lookupVotingServices(tickets []*StakeTicket) {
+	for _, ticket := range tickets {
+		ticket.vsp = s.ticketVSPs[ticket.hash]
+	}
+}
+
+// votingServiceMisses returns the winning tickets of the block at the provided
+// height that miss their votes when VSPs are simulated.  Each ticket delegated
+// to a VSP independently misses when the VSP is not online according to its
+// uptime, while the missed vote model decides how many of the tickets held by
+// solo stakers miss.  No more than the provided maximum number of tickets
+// miss so the block still has the required majority of votes.
+func (s *Simulator) votingServiceMisses(nextHeight int32, maxMisses uint16) (map[chainhash.Hash]struct{}, error) {
+	winners, err := s.lotteryWinners(nextHeight)
+	if err != nil {
+		return nil, err
+	}
+	s.lookupVotingServices(winners)
+
+	isMissed := make([]bool, len(winners))
+	var solo []int
+	for i, ticket := range winners {
+		if ticket.vsp == nil {
+			solo = append(solo, i)
+			continue
+		}
+		isMissed[i] = s.rng.Float64() >= ticket.vsp.uptime
+	}
+	soloMisses := int(s.missFunc(nextHeight, uint16(len(solo))))
+	if soloMisses > len(solo) {
+		soloMisses = len(solo)
+	}
+	for _, i := range solo[len(solo)-soloMisses:] {
+		isMissed[i] = true
+	}
+
+	missed := make(map[chainhash.Hash]struct{})
+	for i, ticket := range winners {
+		if isMissed[i] && len(missed) < int(maxMisses) {
+			missed[ticket.hash] = struct{}{}
+		}
+	}
+	return missed, nil
+}